@@ -0,0 +1,165 @@
+package freepslib
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HAN-FUN unit types reported in etsiunitinfo
+const (
+	UnitTypeSimpleOnOffSwitchable       = 256
+	UnitTypeSimpleOnOffSwitch           = 257
+	UnitTypeACOutlet                    = 262
+	UnitTypeACOutletSimplePowerMetering = 263
+	UnitTypeSimpleLight                 = 264
+	UnitTypeDimmableLight               = 265
+	UnitTypeDimmerSwitch                = 266
+	UnitTypeColorBulb                   = 273
+	UnitTypeDimmableColorBulb           = 274
+	UnitTypeBlind                       = 281
+	UnitTypeLamellar                    = 282
+	UnitTypeSimpleDetector              = 512
+	UnitTypeDoorOpenCloseDetector       = 513
+	UnitTypeWindowOpenCloseDetector     = 514
+	UnitTypeMotionDetector              = 515
+	UnitTypeFloodDetector               = 518
+	UnitTypeGlassBreakDetector          = 519
+	UnitTypeVibrationDetector           = 520
+	UnitTypeSiren                       = 640
+)
+
+// HAN-FUN interfaces reported in etsiunitinfo
+const (
+	InterfaceAlert           = 256
+	InterfaceKeepAlive       = 277
+	InterfaceOnOff           = 512
+	InterfaceLevelControl    = 513
+	InterfaceColorControl    = 514
+	InterfaceOpenClose       = 516
+	InterfaceOpenCloseConfig = 517
+	InterfaceSimpleButton    = 772
+	InterfaceSuotaUpdate     = 1024
+)
+
+// InterfaceList returns the comma-separated interfaces as numbers, unparsable entries are skipped
+func (u *AvmEtsiUnitInfo) InterfaceList() []int {
+	r := []int{}
+	for _, s := range strings.Split(u.Interfaces, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(s))
+		if err == nil {
+			r = append(r, i)
+		}
+	}
+	return r
+}
+
+func (u *AvmEtsiUnitInfo) HasInterface(iface int) bool {
+	for _, i := range u.InterfaceList() {
+		if i == iface {
+			return true
+		}
+	}
+	return false
+}
+
+// AlertKind determines how the alert state of a device is interpreted
+type AlertKind int
+
+const (
+	// AlertKindGeneric is used for DECT devices and unknown unit types, the state is 0 or 1
+	AlertKindGeneric AlertKind = iota
+	// AlertKindContact are door/window sensors, the alarm bit means open
+	AlertKindContact
+	// AlertKindDetector are motion, flood, glass break, vibration and other simple detectors
+	AlertKindDetector
+	// AlertKindBlind are roller shutters reporting obstacle and overtemperature alarms
+	AlertKindBlind
+)
+
+func (k AlertKind) String() string {
+	switch k {
+	case AlertKindContact:
+		return "contact"
+	case AlertKindDetector:
+		return "detector"
+	case AlertKindBlind:
+		return "blind"
+	}
+	return "generic"
+}
+
+// AlertKind picks the interpretation of the alert state based on the unit type and its interfaces. Units
+// listing interfaces but not the alert interface, like a simple on/off switch that is just a button
+// (InterfaceSimpleButton), are generic; a unit with InterfaceOpenClose is a blind.
+func (u *AvmEtsiUnitInfo) AlertKind() AlertKind {
+	switch u.UnitType {
+	case UnitTypeBlind, UnitTypeLamellar:
+		return AlertKindBlind
+	}
+	if u.HasInterface(InterfaceOpenClose) {
+		return AlertKindBlind
+	}
+	if strings.TrimSpace(u.Interfaces) != "" && !u.HasInterface(InterfaceAlert) {
+		return AlertKindGeneric
+	}
+	switch u.UnitType {
+	case UnitTypeSimpleOnOffSwitch, UnitTypeDoorOpenCloseDetector, UnitTypeWindowOpenCloseDetector:
+		return AlertKindContact
+	case UnitTypeSimpleDetector, UnitTypeMotionDetector, UnitTypeFloodDetector, UnitTypeGlassBreakDetector, UnitTypeVibrationDetector:
+		return AlertKindDetector
+	}
+	return AlertKindGeneric
+}
+
+// AvmAlertState is the bitmask sent in the state element of alert, the meaning of a bit depends on the AlertKind.
+// AVM only documents the alarm bit and, for blinds, the overtemperature bit; tamper or battery alarms of
+// HAN-FUN detectors have no documented bits, so other bits are kept in the state but not named.
+type AvmAlertState uint32
+
+const (
+	// AlertStateAlarm is set for an open contact, a triggered detector or an obstacle in front of a blind
+	AlertStateAlarm AvmAlertState = 1 << 0
+	// AlertStateOverTemperature is set if the motor of a blind is too hot
+	AlertStateOverTemperature AvmAlertState = 1 << 1
+
+	AlertStateObstacle = AlertStateAlarm
+)
+
+// Has returns true if all bits of flag are set
+func (s AvmAlertState) Has(flag AvmAlertState) bool {
+	return s&flag == flag
+}
+
+// AvmAlertStatus is the decoded alert element of a device
+type AvmAlertStatus struct {
+	Kind       AlertKind
+	State      AvmAlertState
+	LastChange time.Time
+}
+
+func (s AvmAlertStatus) Alarm() bool {
+	return s.State.Has(AlertStateAlarm)
+}
+
+// AlertStatus decodes the alert element using the unit type, ok is false if the device has no alert
+func (d *AvmDevice) AlertStatus() (status AvmAlertStatus, ok bool) {
+	if d.Alert == nil {
+		return status, false
+	}
+	status.State = AvmAlertState(d.Alert.State)
+	status.LastChange = unixTime(d.Alert.LastAlertChgTimestamp)
+	if d.EtsiUnitInfo != nil {
+		status.Kind = d.EtsiUnitInfo.AlertKind()
+	}
+	return status, true
+}
+
+// IsOpen returns true if the door or window is open, ok is false if the device is no contact sensor
+func (d *AvmDevice) IsOpen() (open bool, ok bool) {
+	status, ok := d.AlertStatus()
+	if !ok || status.Kind != AlertKindContact {
+		return false, false
+	}
+	return status.Alarm(), true
+}
@@ -0,0 +1,74 @@
+package freepslib
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAlertStatus(t *testing.T) {
+	byteValue, err := os.ReadFile("./_testdata/large_devicelist.xml")
+	assert.NilError(t, err)
+	dl, err := parseDeviceList(byteValue)
+	assert.NilError(t, err)
+
+	devices := map[string]*AvmDevice{}
+	for i := range dl.Device {
+		devices[dl.Device[i].AIN] = &dl.Device[i]
+	}
+
+	sensor := devices["ZDANON02"]
+	assert.DeepEqual(t, sensor.EtsiUnitInfo.InterfaceList(), []int{InterfaceAlert})
+	assert.Assert(t, sensor.EtsiUnitInfo.HasInterface(InterfaceAlert))
+	status, ok := sensor.AlertStatus()
+	assert.Assert(t, ok)
+	assert.Equal(t, status.Kind, AlertKindContact)
+	assert.Assert(t, status.Alarm())
+	assert.Equal(t, status.LastChange, time.Unix(1741512765, 0))
+	open, ok := sensor.IsOpen()
+	assert.Assert(t, ok)
+	assert.Assert(t, open)
+
+	open, ok = devices["ZDANON03"].IsOpen()
+	assert.Assert(t, ok && open)
+	open, ok = devices["ZDANON05"].IsOpen()
+	assert.Assert(t, ok && !open)
+
+	// the Zigbee device itself has no alert, only its unit
+	_, ok = devices["ZDANON01"].IsOpen()
+	assert.Assert(t, !ok)
+}
+
+func TestAlertKinds(t *testing.T) {
+	blind := AvmDevice{
+		Alert:        &AvmDeviceAlert{State: 2},
+		EtsiUnitInfo: &AvmEtsiUnitInfo{UnitType: UnitTypeBlind, Interfaces: "256, 513,516"},
+	}
+	assert.DeepEqual(t, blind.EtsiUnitInfo.InterfaceList(), []int{InterfaceAlert, InterfaceLevelControl, InterfaceOpenClose})
+	status, ok := blind.AlertStatus()
+	assert.Assert(t, ok)
+	assert.Equal(t, status.Kind, AlertKindBlind)
+	assert.Assert(t, status.State.Has(AlertStateOverTemperature))
+	assert.Assert(t, !status.State.Has(AlertStateObstacle))
+	assert.Assert(t, status.LastChange.IsZero())
+	_, ok = blind.IsOpen()
+	assert.Assert(t, !ok)
+
+	smoke := AvmDevice{Alert: &AvmDeviceAlert{State: 1}}
+	status, _ = smoke.AlertStatus()
+	assert.Equal(t, status.Kind, AlertKindGeneric)
+	assert.Equal(t, status.Kind.String(), "generic")
+	assert.Assert(t, status.Alarm())
+
+	motion := AvmEtsiUnitInfo{UnitType: UnitTypeMotionDetector}
+	assert.Equal(t, motion.AlertKind(), AlertKindDetector)
+
+	button := AvmEtsiUnitInfo{UnitType: UnitTypeSimpleOnOffSwitch, Interfaces: "772"}
+	assert.Equal(t, button.AlertKind(), AlertKindGeneric)
+	contact := AvmEtsiUnitInfo{UnitType: UnitTypeSimpleOnOffSwitch, Interfaces: "256"}
+	assert.Equal(t, contact.AlertKind(), AlertKindContact)
+	openClose := AvmEtsiUnitInfo{UnitType: UnitTypeSimpleOnOffSwitchable, Interfaces: "256,516"}
+	assert.Equal(t, openClose.AlertKind(), AlertKindBlind)
+}
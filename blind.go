@@ -0,0 +1,59 @@
+package freepslib
+
+import (
+	"context"
+)
+
+const (
+	BlindModeAuto   = "auto"
+	BlindModeManual = "manuell"
+)
+
+// BlindPosition returns the current position of a blind in percent, ok is false if the device does not report one
+func (d *AvmDevice) BlindPosition() (percent int, ok bool) {
+	if d.Blind == nil || d.LevelControl == nil {
+		return 0, false
+	}
+	return int(d.LevelControl.LevelPercentage), true
+}
+
+func (f *Freeps) setBlind(ctx context.Context, ain string, target string) error {
+	_, err := f.queryHomeAutomation(ctx, "setblind", ain, map[string]string{"target": target})
+	return err
+}
+
+// BlindOpen opens the blind completely
+func (f *Freeps) BlindOpen(ain string) error {
+	return f.BlindOpenContext(context.Background(), ain)
+}
+
+func (f *Freeps) BlindOpenContext(ctx context.Context, ain string) error {
+	return f.setBlind(ctx, ain, "open")
+}
+
+// BlindClose closes the blind completely
+func (f *Freeps) BlindClose(ain string) error {
+	return f.BlindCloseContext(context.Background(), ain)
+}
+
+func (f *Freeps) BlindCloseContext(ctx context.Context, ain string) error {
+	return f.setBlind(ctx, ain, "close")
+}
+
+// BlindStop stops a moving blind
+func (f *Freeps) BlindStop(ain string) error {
+	return f.BlindStopContext(context.Background(), ain)
+}
+
+func (f *Freeps) BlindStopContext(ctx context.Context, ain string) error {
+	return f.setBlind(ctx, ain, "stop")
+}
+
+// BlindSetPosition moves the blind to the given position in percent (0-100)
+func (f *Freeps) BlindSetPosition(ain string, percent int) error {
+	return f.BlindSetPositionContext(context.Background(), ain, percent)
+}
+
+func (f *Freeps) BlindSetPositionContext(ctx context.Context, ain string, percent int) error {
+	return f.SetLevelPercentageContext(ctx, ain, percent)
+}
@@ -0,0 +1,62 @@
+package freepslib
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const blindDeviceXML = `<devicelist version="1" fwversion="7.57">
+<device identifier="14276 0000001-1" id="2000" functionbitmask="335888" fwversion="0.0" manufacturer="0x0feb" productname="Rollotron 1213">
+<present>1</present><txbusy>0</txbusy><name>Rollladen Wohnzimmer</name>
+<blind><endpositionsset>1</endpositionsset><mode>manuell</mode></blind>
+<levelcontrol><level>64</level><levelpercentage>25</levelpercentage></levelcontrol>
+</device>
+</devicelist>`
+
+func TestBlindUnmarshal(t *testing.T) {
+	dl, err := parseDeviceList([]byte(blindDeviceXML))
+	assert.NilError(t, err)
+	assert.Equal(t, len(dl.Device), 1)
+
+	d := dl.Device[0]
+	assert.Assert(t, d.IsBlind())
+	assert.DeepEqual(t, d.Blind, &AvmDeviceBlind{EndPositionsSet: true, Mode: BlindModeManual})
+	percent, ok := d.BlindPosition()
+	assert.Assert(t, ok)
+	assert.Equal(t, percent, 25)
+
+	_, ok = (&AvmDevice{}).BlindPosition()
+	assert.Assert(t, !ok)
+}
+
+func TestBlindControl(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	var targets []string
+	box.handleSwitch("setblind", func(w http.ResponseWriter, r *http.Request) {
+		targets = append(targets, r.URL.Query().Get("target"))
+	})
+	var levels []string
+	box.handleSwitch("setlevelpercentage", func(w http.ResponseWriter, r *http.Request) {
+		levels = append(levels, r.URL.Query().Get("level"))
+	})
+
+	ain := "14276 0000001-1"
+	assert.NilError(t, f.BlindOpen(ain))
+	assert.NilError(t, f.BlindClose(ain))
+	assert.NilError(t, f.BlindStop(ain))
+	assert.DeepEqual(t, targets, []string{"open", "close", "stop"})
+
+	assert.NilError(t, f.BlindSetPosition(ain, 0))
+	assert.NilError(t, f.BlindSetPosition(ain, 40))
+	assert.DeepEqual(t, levels, []string{"0", "40"})
+
+	for _, percent := range []int{-1, 101} {
+		assert.Assert(t, errors.Is(f.BlindSetPosition(ain, percent), ErrOutOfRange), percent)
+	}
+	assert.Equal(t, len(levels), 2)
+}
@@ -0,0 +1,97 @@
+package freepslib
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MaxHkrEndTime is the maximum duration a thermostat accepts for boost and window open mode
+const MaxHkrEndTime = 24 * time.Hour
+
+// unixTime converts timestamps of the AHA interface, 0 means not set
+func unixTime(timestamp int) time.Time {
+	if timestamp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(timestamp), 0)
+}
+
+// BoostEndTime returns when boost mode ends, the zero time if it is not active
+func (h *AvmDeviceHkr) BoostEndTime() time.Time {
+	if !h.Boostactive {
+		return time.Time{}
+	}
+	return unixTime(h.Boostactiveendtime)
+}
+
+// WindowOpenEndTime returns when window open mode ends, the zero time if it is not active
+func (h *AvmDeviceHkr) WindowOpenEndTime() time.Time {
+	if !h.Windowopenactive {
+		return time.Time{}
+	}
+	return unixTime(h.Windowopenactiveendtime)
+}
+
+// setHkrEndTime sends switchcmd with an end timestamp duration from now, 0 stops the mode
+func (f *Freeps) setHkrEndTime(ctx context.Context, switchcmd string, ain string, duration time.Duration) (time.Time, error) {
+	endtimestamp := int64(0)
+	if duration != 0 {
+		if duration < time.Second || duration > MaxHkrEndTime {
+			return time.Time{}, fmt.Errorf("%w: duration %v is not between 1s and %v", ErrOutOfRange, duration, MaxHkrEndTime)
+		}
+		endtimestamp = time.Now().Add(duration).Unix()
+	}
+
+	byt, err := f.queryHomeAutomation(ctx, switchcmd, ain, map[string]string{"endtimestamp": fmt.Sprint(endtimestamp)})
+	if err != nil {
+		return time.Time{}, err
+	}
+	end, err := parseIntValue(ain, byt)
+	if err != nil {
+		return time.Time{}, f.checkPresent(ctx, ain, err)
+	}
+	return unixTime(end), nil
+}
+
+// StartBoost heats at full power for the given duration (at most 24h) and returns when boost mode ends
+func (f *Freeps) StartBoost(ain string, duration time.Duration) (time.Time, error) {
+	return f.StartBoostContext(context.Background(), ain, duration)
+}
+
+func (f *Freeps) StartBoostContext(ctx context.Context, ain string, duration time.Duration) (time.Time, error) {
+	if duration == 0 {
+		return time.Time{}, fmt.Errorf("%w: use StopBoost to end boost mode", ErrOutOfRange)
+	}
+	return f.setHkrEndTime(ctx, "sethkrboost", ain, duration)
+}
+
+func (f *Freeps) StopBoost(ain string) error {
+	return f.StopBoostContext(context.Background(), ain)
+}
+
+func (f *Freeps) StopBoostContext(ctx context.Context, ain string) error {
+	_, err := f.setHkrEndTime(ctx, "sethkrboost", ain, 0)
+	return err
+}
+
+// StartWindowOpen turns off heating for the given duration (at most 24h) and returns when window open mode ends
+func (f *Freeps) StartWindowOpen(ain string, duration time.Duration) (time.Time, error) {
+	return f.StartWindowOpenContext(context.Background(), ain, duration)
+}
+
+func (f *Freeps) StartWindowOpenContext(ctx context.Context, ain string, duration time.Duration) (time.Time, error) {
+	if duration == 0 {
+		return time.Time{}, fmt.Errorf("%w: use StopWindowOpen to end window open mode", ErrOutOfRange)
+	}
+	return f.setHkrEndTime(ctx, "sethkrwindowopen", ain, duration)
+}
+
+func (f *Freeps) StopWindowOpen(ain string) error {
+	return f.StopWindowOpenContext(context.Background(), ain)
+}
+
+func (f *Freeps) StopWindowOpenContext(ctx context.Context, ain string) error {
+	_, err := f.setHkrEndTime(ctx, "sethkrwindowopen", ain, 0)
+	return err
+}
@@ -0,0 +1,67 @@
+package freepslib
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBoostAndWindowOpen(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	received := map[string]int64{}
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		end, err := strconv.ParseInt(r.URL.Query().Get("endtimestamp"), 10, 64)
+		assert.Check(t, err)
+		received[r.URL.Query().Get("switchcmd")] = end
+		w.Write([]byte(r.URL.Query().Get("endtimestamp") + "\n"))
+	}
+	box.handleSwitch("sethkrboost", echo)
+	box.handleSwitch("sethkrwindowopen", echo)
+
+	before := time.Now()
+	end, err := f.StartBoost("11795 ANON01", time.Hour)
+	assert.NilError(t, err)
+	assert.Assert(t, end.Unix() >= before.Add(time.Hour).Unix())
+	assert.Assert(t, end.Before(time.Now().Add(time.Hour+time.Second)))
+	assert.Equal(t, received["sethkrboost"], end.Unix())
+
+	end, err = f.StartWindowOpen("11795 ANON01", 24*time.Hour)
+	assert.NilError(t, err)
+	assert.Equal(t, received["sethkrwindowopen"], end.Unix())
+
+	assert.NilError(t, f.StopBoost("11795 ANON01"))
+	assert.Equal(t, received["sethkrboost"], int64(0))
+	assert.NilError(t, f.StopWindowOpen("11795 ANON01"))
+	assert.Equal(t, received["sethkrwindowopen"], int64(0))
+
+	for _, d := range []time.Duration{0, -time.Minute, 24*time.Hour + time.Second} {
+		_, err = f.StartBoost("11795 ANON01", d)
+		assert.Assert(t, errors.Is(err, ErrOutOfRange), d)
+		_, err = f.StartWindowOpen("11795 ANON01", d)
+		assert.Assert(t, errors.Is(err, ErrOutOfRange), d)
+	}
+}
+
+func TestBoostNotPresent(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("sethkrboost", "inval")
+	presentOnly(box, "11795 ANON01")
+
+	_, err := f.StartBoost("11795 ANON02", time.Hour)
+	assert.Assert(t, errors.Is(err, ErrDeviceNotPresent), err)
+	_, err = f.StartBoost("11795 ANON01", time.Hour)
+	assert.Assert(t, errors.Is(err, ErrInvalidValue), err)
+}
+
+func TestHkrEndTimes(t *testing.T) {
+	hkr := AvmDeviceHkr{Boostactive: true, Boostactiveendtime: 1741545900, Windowopenactiveendtime: 1741545900}
+	assert.Equal(t, hkr.BoostEndTime(), time.Unix(1741545900, 0))
+	assert.Assert(t, hkr.WindowOpenEndTime().IsZero())
+}
@@ -0,0 +1,133 @@
+package freepslib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ButtonKind distinguishes short and long presses for buttons that report them separately
+type ButtonKind int
+
+const (
+	ButtonKindUnknown ButtonKind = iota
+	ButtonKindShort
+	ButtonKindLong
+)
+
+func (k ButtonKind) String() string {
+	switch k {
+	case ButtonKindShort:
+		return "short"
+	case ButtonKindLong:
+		return "long"
+	}
+	return "unknown"
+}
+
+// ButtonPressed is emitted by the Watcher once for every press of a button, Time is the time of the press
+type ButtonPressed struct {
+	DeviceEventBase
+	ButtonID string // identifier of the button, the AIN of the device for Zigbee remotes with one button per unit
+	Name     string
+	Kind     ButtonKind
+}
+
+// Kind guesses the press type from the identifier of the FRITZ!DECT 400 (-0 short, -9 long) or the name
+func (b *AvmButton) Kind() ButtonKind {
+	if b.AIN != nil {
+		switch {
+		case strings.HasSuffix(*b.AIN, "-0"):
+			return ButtonKindShort
+		case strings.HasSuffix(*b.AIN, "-9"):
+			return ButtonKindLong
+		}
+	}
+	if b.Name != nil {
+		name := strings.ToLower(*b.Name)
+		if strings.Contains(name, "lang") || strings.Contains(name, "long") {
+			return ButtonKindLong
+		}
+		if strings.Contains(name, "kurz") || strings.Contains(name, "short") {
+			return ButtonKindShort
+		}
+	}
+	return ButtonKindUnknown
+}
+
+// buttonTracker remembers the last press of every button, optionally persisted in a JSON file
+type buttonTracker struct {
+	file     string
+	lastSeen map[string]int
+	loaded   bool
+}
+
+func (t *buttonTracker) load() error {
+	t.loaded = true
+	t.lastSeen = map[string]int{}
+	if t.file == "" {
+		return nil
+	}
+	byt, err := os.ReadFile(t.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read button state: %w", err)
+	}
+	if err := json.Unmarshal(byt, &t.lastSeen); err != nil {
+		return &ParseError{Format: "JSON", Body: byt, Err: err}
+	}
+	return nil
+}
+
+func (t *buttonTracker) save() error {
+	if t.file == "" {
+		return nil
+	}
+	byt, err := json.Marshal(t.lastSeen)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.file, byt, 0600); err != nil {
+		return fmt.Errorf("cannot store button state: %w", err)
+	}
+	return nil
+}
+
+// presses returns an event for every button of dev pressed since the last call. A button seen for the first
+// time only produces an event if emitNew is set; an empty lastpressedtimestamp means it was never pressed.
+func (t *buttonTracker) presses(dev *AvmDevice, emitNew bool) (events []Event, changed bool) {
+	for _, b := range dev.ButtonFunctions {
+		if b.LastPressedTimestamp == 0 {
+			continue
+		}
+		id := dev.AIN
+		if b.AIN != nil {
+			id = *b.AIN
+		}
+		last, known := t.lastSeen[id]
+		if known && b.LastPressedTimestamp <= last {
+			continue
+		}
+		t.lastSeen[id] = b.LastPressedTimestamp
+		changed = true
+		if !known && !emitNew {
+			continue
+		}
+		name := dev.Name
+		if b.Name != nil {
+			name = *b.Name
+		}
+		events = append(events, ButtonPressed{
+			DeviceEventBase: DeviceEventBase{AIN: dev.AIN, Time: time.Unix(int64(b.LastPressedTimestamp), 0)},
+			ButtonID:        id,
+			Name:            name,
+			Kind:            b.Kind(),
+		})
+	}
+	return events, changed
+}
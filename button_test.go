@@ -0,0 +1,89 @@
+package freepslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func dect400XML(short string, long string) string {
+	return fmt.Sprintf(`<device identifier="13076 0019379" id="24" functionbitmask="32" productname="FRITZ!DECT 400"><present>1</present><name>Taste 1</name>`+
+		`<button identifier="13076 0019379-0" id="5000"><name>Taste 1 kurz</name><lastpressedtimestamp>%v</lastpressedtimestamp></button>`+
+		`<button identifier="13076 0019379-9" id="5001"><name>Taste 1 lang</name><lastpressedtimestamp>%v</lastpressedtimestamp></button></device>`, short, long)
+}
+
+func remoteUnitXML(ain string, timestamp string) string {
+	return fmt.Sprintf(`<device identifier="%v" id="2008" functionbitmask="8200"><present>1</present><name>Remote %v</name>`+
+		`<button><lastpressedtimestamp>%v</lastpressedtimestamp></button></device>`, ain, ain, timestamp)
+}
+
+func TestButtonKind(t *testing.T) {
+	ain := func(s string) *string { return &s }
+	assert.Equal(t, (&AvmButton{AIN: ain("13096 ANON01-0")}).Kind(), ButtonKindShort)
+	assert.Equal(t, (&AvmButton{AIN: ain("13096 ANON01-9")}).Kind(), ButtonKindLong)
+	assert.Equal(t, (&AvmButton{AIN: ain("09995 0000001-1"), Name: ain("Oben rechts lang")}).Kind(), ButtonKindLong)
+	assert.Equal(t, (&AvmButton{}).Kind(), ButtonKindUnknown)
+	assert.Equal(t, ButtonKindShort.String(), "short")
+}
+
+func TestButtonPressed(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	list := &fakeDeviceList{}
+	list.register(box)
+	stateFile := filepath.Join(t.TempDir(), "buttons.json")
+	w := f.NewWatcher(WatcherConfig{Clock: newFakeClock(), ButtonStateFile: stateFile})
+	ctx := context.Background()
+
+	// presses before the first poll are not reported, an empty timestamp means never pressed
+	list.set(dect400XML("1741514032", ""), remoteUnitXML("Z9ANON02", "1741377977"))
+	events, err := w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	list.set(dect400XML("1741514032", "1741514100"), remoteUnitXML("Z9ANON02", "1741514200"))
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, events, []Event{
+		ButtonPressed{DeviceEventBase{"13076 0019379", time.Unix(1741514100, 0)}, "13076 0019379-9", "Taste 1 lang", ButtonKindLong},
+		ButtonPressed{DeviceEventBase{"Z9ANON02", time.Unix(1741514200, 0)}, "Z9ANON02", "Remote Z9ANON02", ButtonKindUnknown},
+	})
+
+	// every press is reported once
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	// a restarted watcher reports the presses it missed
+	list.set(dect400XML("1741514300", "1741514100"), remoteUnitXML("Z9ANON02", "1741514200"), remoteUnitXML("Z9ANON03", "1741514400"))
+	w = f.NewWatcher(WatcherConfig{Clock: newFakeClock(), ButtonStateFile: stateFile})
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, events, []Event{
+		ButtonPressed{DeviceEventBase{"13076 0019379", time.Unix(1741514300, 0)}, "13076 0019379-0", "Taste 1 kurz", ButtonKindShort},
+	})
+
+	// buttons of devices added later are reported on their first press
+	list.set(dect400XML("1741514300", "1741514100"), remoteUnitXML("Z9ANON02", "1741514200"), remoteUnitXML("Z9ANON03", "1741514400"), remoteUnitXML("Z9ANON04", "1741514500"))
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 2)
+	assert.Equal(t, events[0].(ButtonPressed).ButtonID, "Z9ANON04")
+	_, ok := events[1].(DeviceAppeared)
+	assert.Assert(t, ok)
+
+	info, err := os.Stat(stateFile)
+	assert.NilError(t, err)
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+
+	os.WriteFile(stateFile, []byte("not json"), 0600)
+	_, err = f.NewWatcher(WatcherConfig{ButtonStateFile: stateFile}).Poll(ctx)
+	var parseErr *ParseError
+	assert.Assert(t, errors.As(err, &parseErr))
+}
@@ -0,0 +1,100 @@
+package freepslib
+
+// AvmFunctionBitmask is the functionbitmask attribute of a device, it describes what a device can do
+type AvmFunctionBitmask uint32
+
+// bit positions as documented in the AHA-HTTP-Interface
+const (
+	FunctionHanFunDevice      AvmFunctionBitmask = 1 << 0
+	FunctionLight             AvmFunctionBitmask = 1 << 2
+	FunctionAlarmSensor       AvmFunctionBitmask = 1 << 4
+	FunctionButton            AvmFunctionBitmask = 1 << 5
+	FunctionThermostat        AvmFunctionBitmask = 1 << 6
+	FunctionEnergyMeter       AvmFunctionBitmask = 1 << 7
+	FunctionTemperatureSensor AvmFunctionBitmask = 1 << 8
+	FunctionSwitch            AvmFunctionBitmask = 1 << 9
+	FunctionDectRepeater      AvmFunctionBitmask = 1 << 10
+	FunctionMicrophone        AvmFunctionBitmask = 1 << 11
+	FunctionHanFunUnit        AvmFunctionBitmask = 1 << 13
+	FunctionOnOff             AvmFunctionBitmask = 1 << 15
+	FunctionLevel             AvmFunctionBitmask = 1 << 16
+	FunctionColor             AvmFunctionBitmask = 1 << 17
+	FunctionBlind             AvmFunctionBitmask = 1 << 18
+	FunctionHumiditySensor    AvmFunctionBitmask = 1 << 20
+)
+
+// Has returns true if all bits of function are set
+func (b AvmFunctionBitmask) Has(function AvmFunctionBitmask) bool {
+	return b&function == function
+}
+
+// IsHanFunDevice returns true for HAN-FUN devices, their functions are provided by separate HAN-FUN units
+func (d *AvmDevice) IsHanFunDevice() bool {
+	return d.FunctionBitmask.Has(FunctionHanFunDevice)
+}
+
+// IsHanFunUnit returns true for a unit of a HAN-FUN device, see EtsiUnitInfo
+func (d *AvmDevice) IsHanFunUnit() bool {
+	return d.FunctionBitmask.Has(FunctionHanFunUnit)
+}
+
+func (d *AvmDevice) IsLight() bool {
+	return d.FunctionBitmask.Has(FunctionLight)
+}
+
+func (d *AvmDevice) IsAlarmSensor() bool {
+	return d.FunctionBitmask.Has(FunctionAlarmSensor)
+}
+
+// IsButton returns true for AVM buttons like the FRITZ!DECT 400 and 440
+func (d *AvmDevice) IsButton() bool {
+	return d.FunctionBitmask.Has(FunctionButton)
+}
+
+func (d *AvmDevice) IsThermostat() bool {
+	return d.FunctionBitmask.Has(FunctionThermostat)
+}
+
+func (d *AvmDevice) HasEnergyMeter() bool {
+	return d.FunctionBitmask.Has(FunctionEnergyMeter)
+}
+
+func (d *AvmDevice) HasTemperatureSensor() bool {
+	return d.FunctionBitmask.Has(FunctionTemperatureSensor)
+}
+
+// HasSwitch returns true for outlets that can be controlled with setswitchon/setswitchoff
+func (d *AvmDevice) HasSwitch() bool {
+	return d.FunctionBitmask.Has(FunctionSwitch)
+}
+
+func (d *AvmDevice) IsDectRepeater() bool {
+	return d.FunctionBitmask.Has(FunctionDectRepeater)
+}
+
+func (d *AvmDevice) HasMicrophone() bool {
+	return d.FunctionBitmask.Has(FunctionMicrophone)
+}
+
+// HasOnOff returns true for devices that can be controlled with setsimpleonoff
+func (d *AvmDevice) HasOnOff() bool {
+	return d.FunctionBitmask.Has(FunctionOnOff)
+}
+
+// HasLevel returns true for devices with an adjustable level, e.g. dimmable lights or blinds
+func (d *AvmDevice) HasLevel() bool {
+	return d.FunctionBitmask.Has(FunctionLevel)
+}
+
+// HasColor returns true for lights with adjustable color or color temperature
+func (d *AvmDevice) HasColor() bool {
+	return d.FunctionBitmask.Has(FunctionColor)
+}
+
+func (d *AvmDevice) IsBlind() bool {
+	return d.FunctionBitmask.Has(FunctionBlind)
+}
+
+func (d *AvmDevice) HasHumiditySensor() bool {
+	return d.FunctionBitmask.Has(FunctionHumiditySensor)
+}
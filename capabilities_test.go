@@ -0,0 +1,75 @@
+package freepslib
+
+import (
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	byteValue, err := os.ReadFile("./_testdata/test_devicelist.xml")
+	assert.NilError(t, err)
+	dl, err := parseDeviceList(byteValue)
+	assert.NilError(t, err)
+
+	outlet := dl.Device[0]
+	assert.Equal(t, outlet.FunctionBitmask, AvmFunctionBitmask(896))
+	assert.Equal(t, outlet.FwVersion, "03.33")
+	assert.Equal(t, outlet.Manufacturer, "AVM")
+	assert.Assert(t, outlet.HasSwitch())
+	assert.Assert(t, outlet.HasEnergyMeter())
+	assert.Assert(t, outlet.HasTemperatureSensor())
+	assert.Assert(t, !outlet.IsThermostat())
+	assert.Assert(t, !outlet.IsDectRepeater())
+
+	repeater := dl.Device[1]
+	assert.Assert(t, repeater.IsDectRepeater())
+	assert.Assert(t, repeater.HasTemperatureSensor())
+	assert.Assert(t, !repeater.HasSwitch())
+
+	button := dl.Device[2]
+	assert.Assert(t, button.IsButton())
+	assert.Assert(t, !button.HasTemperatureSensor())
+}
+
+func TestCapabilitiesLarge(t *testing.T) {
+	byteValue, err := os.ReadFile("./_testdata/large_devicelist.xml")
+	assert.NilError(t, err)
+	dl, err := parseDeviceList(byteValue)
+	assert.NilError(t, err)
+
+	for _, dev := range dl.Device {
+		switch dev.AIN {
+		case "11795 ANON01":
+			assert.Assert(t, dev.IsThermostat())
+			assert.Assert(t, dev.HKR != nil)
+		case "13077 ANON02":
+			assert.Assert(t, dev.IsLight())
+			assert.Assert(t, dev.IsHanFunUnit())
+			assert.Assert(t, dev.HasOnOff())
+			assert.Assert(t, dev.HasLevel())
+			assert.Assert(t, dev.HasColor())
+			assert.Assert(t, !dev.IsBlind())
+		case "ZDANON01":
+			assert.Assert(t, dev.IsHanFunDevice())
+			assert.Equal(t, dev.Manufacturer, "0x117c")
+		case "ZDANON02":
+			assert.Assert(t, dev.IsHanFunUnit())
+			assert.Assert(t, dev.IsAlarmSensor())
+			assert.Assert(t, dev.Alert != nil)
+		}
+		// capabilities and parsed elements agree
+		assert.Equal(t, dev.IsThermostat(), dev.HKR != nil, dev.AIN)
+		assert.Equal(t, dev.HasSwitch(), dev.Switch != nil, dev.AIN)
+		assert.Equal(t, dev.HasEnergyMeter(), dev.Powermeter != nil, dev.AIN)
+	}
+}
+
+func TestFunctionBitmaskHas(t *testing.T) {
+	b := FunctionBlind | FunctionLevel | FunctionHanFunUnit
+	assert.Assert(t, b.Has(FunctionBlind))
+	assert.Assert(t, b.Has(FunctionBlind|FunctionLevel))
+	assert.Assert(t, !b.Has(FunctionBlind|FunctionMicrophone))
+	assert.Assert(t, !b.Has(FunctionHumiditySensor))
+}
@@ -64,5 +64,9 @@ func main() {
 			json, _ := json.Marshal(d)
 			fmt.Println(string(json))
 		}
+		for _, g := range x.Groups {
+			json, _ := json.Marshal(g)
+			fmt.Println(string(json))
+		}
 	}
 }
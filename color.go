@@ -0,0 +1,292 @@
+package freepslib
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// AvmColorMode is the bitmask used by the supported_modes and current_mode attributes of colorcontrol
+type AvmColorMode uint
+
+const (
+	ColorModeHueSaturation AvmColorMode = 1 << 0
+	ColorModeTemperature   AvmColorMode = 1 << 2
+)
+
+// Has returns true if all bits of mode are set
+func (m AvmColorMode) Has(mode AvmColorMode) bool {
+	return m&mode == mode
+}
+
+type AvmColorPreset struct {
+	SatIndex   int `xml:"sat_index,attr"`
+	Hue        int `xml:"hue,attr"`
+	Saturation int `xml:"sat,attr"`
+	Value      int `xml:"val,attr"`
+}
+
+type AvmHueDefaults struct {
+	HueIndex int              `xml:"hue_index,attr"`
+	Name     string           `xml:"name"`
+	Colors   []AvmColorPreset `xml:"color"`
+}
+
+type AvmTemperaturePreset struct {
+	Value int `xml:"value,attr"`
+}
+
+// AvmColorDefaults holds the presets returned by getcolordefaults, setcolor and setcolortemperature only accept these
+type AvmColorDefaults struct {
+	HueSaturation []AvmHueDefaults       `xml:"hsdefaults>hs"`
+	Temperatures  []AvmTemperaturePreset `xml:"temperaturedefaults>temp"`
+}
+
+func parseColorDefaults(byt []byte) (*AvmColorDefaults, error) {
+	var defaults AvmColorDefaults
+	err := xml.Unmarshal(byt, &defaults)
+	if err != nil {
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+	return &defaults, nil
+}
+
+func hueDistance(a, b int) int {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	d %= 360
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// NearestColor returns the preset closest to the given hue (0-359) and saturation (0-255)
+func (c *AvmColorDefaults) NearestColor(hue int, saturation int) (AvmColorPreset, bool) {
+	var nearest AvmColorPreset
+	found := false
+	best := 0
+	for _, hs := range c.HueSaturation {
+		for _, color := range hs.Colors {
+			// scale hue to the saturation range so both weigh the same
+			dh := hueDistance(hue, color.Hue) * 255 / 180
+			ds := saturation - color.Saturation
+			dist := dh*dh + ds*ds
+			if !found || dist < best {
+				nearest, best, found = color, dist, true
+			}
+		}
+	}
+	return nearest, found
+}
+
+// NearestTemperature returns the preset closest to the given color temperature in Kelvin
+func (c *AvmColorDefaults) NearestTemperature(kelvin int) (int, bool) {
+	nearest := 0
+	found := false
+	best := 0
+	for _, t := range c.Temperatures {
+		dist := kelvin - t.Value
+		if dist < 0 {
+			dist = -dist
+		}
+		if !found || dist < best {
+			nearest, best, found = t.Value, dist, true
+		}
+	}
+	return nearest, found
+}
+
+// transitionDuration converts the duration into the tenths of a second expected by the AHA interface
+func transitionDuration(duration time.Duration) (string, error) {
+	if duration < 0 {
+		return "", fmt.Errorf("%w: negative duration %v", ErrOutOfRange, duration)
+	}
+	return fmt.Sprint(duration.Milliseconds() / 100), nil
+}
+
+func validateColor(hue int, saturation int) error {
+	if hue < 0 || hue > 359 {
+		return fmt.Errorf("%w: hue %v is not between 0 and 359", ErrOutOfRange, hue)
+	}
+	if saturation < 0 || saturation > 255 {
+		return fmt.Errorf("%w: saturation %v is not between 0 and 255", ErrOutOfRange, saturation)
+	}
+	return nil
+}
+
+func (f *Freeps) GetColorDefaults(ain string) (*AvmColorDefaults, error) {
+	return f.GetColorDefaultsContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetColorDefaultsContext(ctx context.Context, ain string) (*AvmColorDefaults, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getcolordefaults", ain, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	return parseColorDefaults(byt)
+}
+
+// colorLamp caches what SetColor and SetColorTemperature need to know about a lamp, it doesn't change at runtime
+type colorLamp struct {
+	fullColor *bool // nil if not fetched yet
+	defaults  *AvmColorDefaults
+}
+
+// getColorLamp returns the cached info about the lamp and fetches what is requested but not cached yet
+func (f *Freeps) getColorLamp(ctx context.Context, ain string, withFullColor bool, withDefaults bool) (colorLamp, error) {
+	f.colorMu.Lock()
+	lamp := f.colorLamps[ain]
+	f.colorMu.Unlock()
+
+	if withFullColor && lamp.fullColor == nil {
+		dev, err := f.GetDeviceContext(ctx, ain)
+		if err != nil {
+			return lamp, err
+		}
+		fullColor := dev.ColorControl != nil && dev.ColorControl.FullColorSupport
+		lamp.fullColor = &fullColor
+	}
+	if withDefaults && lamp.defaults == nil {
+		defaults, err := f.GetColorDefaultsContext(ctx, ain)
+		if err != nil {
+			return lamp, err
+		}
+		lamp.defaults = defaults
+	}
+
+	f.colorMu.Lock()
+	defer f.colorMu.Unlock()
+	if f.colorLamps == nil {
+		f.colorLamps = map[string]colorLamp{}
+	}
+	// merge with what a concurrent call might have stored meanwhile
+	cached := f.colorLamps[ain]
+	if lamp.fullColor == nil {
+		lamp.fullColor = cached.fullColor
+	}
+	if lamp.defaults == nil {
+		lamp.defaults = cached.defaults
+	}
+	f.colorLamps[ain] = lamp
+	return lamp, nil
+}
+
+// SetColor sets the color, the transition takes the given duration. Lamps with fullcolorsupport get the exact
+// color via setunmappedcolor, all others the closest preset from getcolordefaults via setcolor. Whether a lamp
+// supports full colors and its presets are fetched once per AIN.
+func (f *Freeps) SetColor(ain string, hue int, saturation int, duration time.Duration) error {
+	return f.SetColorContext(context.Background(), ain, hue, saturation, duration)
+}
+
+func (f *Freeps) SetColorContext(ctx context.Context, ain string, hue int, saturation int, duration time.Duration) error {
+	if err := validateColor(hue, saturation); err != nil {
+		return err
+	}
+	if _, err := transitionDuration(duration); err != nil {
+		return err
+	}
+	lamp, err := f.getColorLamp(ctx, ain, true, false)
+	if err != nil {
+		return err
+	}
+	if *lamp.fullColor {
+		return f.SetUnmappedColorContext(ctx, ain, hue, saturation, duration)
+	}
+	return f.SetColorPresetContext(ctx, ain, hue, saturation, duration)
+}
+
+// SetColorPreset sets the color preset closest to hue and saturation, regardless of fullcolorsupport
+func (f *Freeps) SetColorPreset(ain string, hue int, saturation int, duration time.Duration) error {
+	return f.SetColorPresetContext(context.Background(), ain, hue, saturation, duration)
+}
+
+func (f *Freeps) SetColorPresetContext(ctx context.Context, ain string, hue int, saturation int, duration time.Duration) error {
+	if err := validateColor(hue, saturation); err != nil {
+		return err
+	}
+	d, err := transitionDuration(duration)
+	if err != nil {
+		return err
+	}
+	lamp, err := f.getColorLamp(ctx, ain, false, true)
+	if err != nil {
+		return err
+	}
+	color, ok := lamp.defaults.NearestColor(hue, saturation)
+	if !ok {
+		return &DeviceError{AIN: ain, Err: fmt.Errorf("%w: no color presets", ErrInvalidValue)}
+	}
+	payload := map[string]string{
+		"hue":        fmt.Sprint(color.Hue),
+		"saturation": fmt.Sprint(color.Saturation),
+		"duration":   d,
+	}
+	_, err = f.queryHomeAutomation(ctx, "setcolor", ain, payload)
+	return err
+}
+
+// SetUnmappedColor sets an arbitrary color, only supported by lamps with fullcolorsupport
+func (f *Freeps) SetUnmappedColor(ain string, hue int, saturation int, duration time.Duration) error {
+	return f.SetUnmappedColorContext(context.Background(), ain, hue, saturation, duration)
+}
+
+func (f *Freeps) SetUnmappedColorContext(ctx context.Context, ain string, hue int, saturation int, duration time.Duration) error {
+	if err := validateColor(hue, saturation); err != nil {
+		return err
+	}
+	d, err := transitionDuration(duration)
+	if err != nil {
+		return err
+	}
+	payload := map[string]string{
+		"hue":        fmt.Sprint(hue),
+		"saturation": fmt.Sprint(saturation),
+		"duration":   d,
+	}
+	_, err = f.queryHomeAutomation(ctx, "setunmappedcolor", ain, payload)
+	return err
+}
+
+// SetColorTemperature sets the color temperature preset closest to kelvin, the transition takes the given duration.
+// The presets are fetched once per AIN.
+func (f *Freeps) SetColorTemperature(ain string, kelvin int, duration time.Duration) error {
+	return f.SetColorTemperatureContext(context.Background(), ain, kelvin, duration)
+}
+
+func (f *Freeps) SetColorTemperatureContext(ctx context.Context, ain string, kelvin int, duration time.Duration) error {
+	d, err := transitionDuration(duration)
+	if err != nil {
+		return err
+	}
+	lamp, err := f.getColorLamp(ctx, ain, false, true)
+	if err != nil {
+		return err
+	}
+	temperature, ok := lamp.defaults.NearestTemperature(kelvin)
+	if !ok {
+		return &DeviceError{AIN: ain, Err: fmt.Errorf("%w: no color temperature presets", ErrInvalidValue)}
+	}
+	payload := map[string]string{
+		"temperature": fmt.Sprint(temperature),
+		"duration":    d,
+	}
+	_, err = f.queryHomeAutomation(ctx, "setcolortemperature", ain, payload)
+	return err
+}
+
+// SetLevelPercentage sets brightness or position in percent (0-100)
+func (f *Freeps) SetLevelPercentage(ain string, percent int) error {
+	return f.SetLevelPercentageContext(context.Background(), ain, percent)
+}
+
+func (f *Freeps) SetLevelPercentageContext(ctx context.Context, ain string, percent int) error {
+	if percent < 0 || percent > 100 {
+		return fmt.Errorf("%w: level %v is not between 0 and 100", ErrOutOfRange, percent)
+	}
+	_, err := f.queryHomeAutomation(ctx, "setlevelpercentage", ain, map[string]string{"level": fmt.Sprint(percent)})
+	return err
+}
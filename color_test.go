@@ -0,0 +1,135 @@
+package freepslib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+const colorDefaultsXML = `<colordefaults><hsdefaults>
+<hs hue_index="1"><name enum="5569">Rot</name>
+<color sat_index="1" hue="358" sat="180" val="230"/><color sat_index="2" hue="358" sat="112" val="237"/><color sat_index="3" hue="358" sat="54" val="245"/></hs>
+<hs hue_index="2"><name enum="5570">Orange</name>
+<color sat_index="1" hue="35" sat="214" val="255"/><color sat_index="2" hue="35" sat="140" val="255"/><color sat_index="3" hue="35" sat="72" val="255"/></hs>
+<hs hue_index="3"><name enum="5576">Blau</name>
+<color sat_index="1" hue="220" sat="188" val="255"/><color sat_index="2" hue="220" sat="114" val="255"/><color sat_index="3" hue="220" sat="58" val="255"/></hs>
+</hsdefaults><temperaturedefaults>
+<temp value="2700"/><temp value="3000"/><temp value="3400"/><temp value="3800"/><temp value="4200"/><temp value="4700"/><temp value="5300"/><temp value="5900"/><temp value="6500"/>
+</temperaturedefaults></colordefaults>`
+
+func TestColorControlUnmarshal(t *testing.T) {
+	byteValue, err := os.ReadFile("./_testdata/large_devicelist.xml")
+	assert.NilError(t, err)
+	dl, err := parseDeviceList(byteValue)
+	assert.NilError(t, err)
+
+	var colors []*AvmDeviceColorcontrol
+	for _, d := range dl.Device {
+		if d.ColorControl != nil {
+			colors = append(colors, d.ColorControl)
+		}
+	}
+	assert.Equal(t, len(colors), 2)
+	assert.DeepEqual(t, *colors[0], AvmDeviceColorcontrol{SupportedModes: 5, CurrentMode: ColorModeHueSaturation, FullColorSupport: true, Mapped: true,
+		Hue: 35, Saturation: 214, UnmappedHue: 15, UnmappedSaturation: 255})
+	assert.Assert(t, colors[0].SupportedModes.Has(ColorModeHueSaturation|ColorModeTemperature))
+	assert.Equal(t, colors[1].CurrentMode, ColorModeTemperature)
+	assert.Equal(t, colors[1].Temperature, 2700)
+	assert.Assert(t, !colors[1].Mapped)
+}
+
+func TestColorDefaults(t *testing.T) {
+	defaults, err := parseColorDefaults([]byte(colorDefaultsXML))
+	assert.NilError(t, err)
+	assert.Equal(t, len(defaults.HueSaturation), 3)
+	assert.Equal(t, defaults.HueSaturation[0].Name, "Rot")
+	assert.DeepEqual(t, defaults.HueSaturation[1].Colors[0], AvmColorPreset{SatIndex: 1, Hue: 35, Saturation: 214, Value: 255})
+	assert.Equal(t, len(defaults.Temperatures), 9)
+
+	color, ok := defaults.NearestColor(2, 170)
+	assert.Assert(t, ok)
+	assert.Equal(t, color.Hue, 358)
+	assert.Equal(t, color.Saturation, 180)
+	color, _ = defaults.NearestColor(230, 60)
+	assert.DeepEqual(t, color, AvmColorPreset{SatIndex: 3, Hue: 220, Saturation: 58, Value: 255})
+
+	kelvin, ok := defaults.NearestTemperature(3100)
+	assert.Assert(t, ok)
+	assert.Equal(t, kelvin, 3000)
+	kelvin, _ = defaults.NearestTemperature(10000)
+	assert.Equal(t, kelvin, 6500)
+
+	_, ok = (&AvmColorDefaults{}).NearestTemperature(3000)
+	assert.Assert(t, !ok)
+}
+
+func TestSetColor(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	defaultsRequests := 0
+	box.handleSwitch("getcolordefaults", func(w http.ResponseWriter, r *http.Request) {
+		defaultsRequests++
+		w.Write([]byte(colorDefaultsXML + "\n"))
+	})
+	box.handleSwitch("getdeviceinfos", func(w http.ResponseWriter, r *http.Request) {
+		fullColor := "0"
+		if r.URL.Query().Get("ain") == "13077 0013108-2" {
+			fullColor = "1"
+		}
+		fmt.Fprintf(w, `<device identifier="%v"><present>1</present><colorcontrol supported_modes="5" current_mode="1" fullcolorsupport="%v" mapped="1"></colorcontrol></device>`+"\n", r.URL.Query().Get("ain"), fullColor)
+	})
+
+	queries := map[string]url.Values{}
+	record := func(w http.ResponseWriter, r *http.Request) {
+		queries[r.URL.Query().Get("switchcmd")] = r.URL.Query()
+	}
+	for _, cmd := range []string{"setcolor", "setunmappedcolor", "setcolortemperature", "setlevelpercentage"} {
+		box.handleSwitch(cmd, record)
+	}
+
+	ain := "13077 0013108-1"
+	assert.NilError(t, f.SetColor(ain, 40, 200, time.Second))
+	assert.Equal(t, queries["setcolor"].Get("hue"), "35")
+	assert.Equal(t, queries["setcolor"].Get("saturation"), "214")
+	assert.Equal(t, queries["setcolor"].Get("duration"), "10")
+
+	// the presets are fetched only once
+	assert.NilError(t, f.SetColor(ain, 220, 60, 0))
+	assert.Equal(t, queries["setcolor"].Get("hue"), "220")
+	assert.Equal(t, queries["setcolor"].Get("saturation"), "58")
+	assert.Equal(t, defaultsRequests, 1)
+
+	// a lamp with fullcolorsupport gets the exact color
+	assert.NilError(t, f.SetColor("13077 0013108-2", 40, 200, time.Second))
+	assert.Equal(t, queries["setunmappedcolor"].Get("ain"), "13077 0013108-2")
+	assert.Equal(t, queries["setunmappedcolor"].Get("hue"), "40")
+	assert.Equal(t, queries["setunmappedcolor"].Get("saturation"), "200")
+	assert.Equal(t, defaultsRequests, 1)
+
+	assert.NilError(t, f.SetColorPreset("13077 0013108-2", 40, 200, 0))
+	assert.Equal(t, queries["setcolor"].Get("ain"), "13077 0013108-2")
+	assert.Equal(t, queries["setcolor"].Get("hue"), "35")
+
+	assert.NilError(t, f.SetUnmappedColor(ain, 40, 200, 0))
+	assert.Equal(t, queries["setunmappedcolor"].Get("hue"), "40")
+	assert.Equal(t, queries["setunmappedcolor"].Get("saturation"), "200")
+	assert.Equal(t, queries["setunmappedcolor"].Get("duration"), "0")
+
+	assert.NilError(t, f.SetColorTemperature(ain, 4000, 500*time.Millisecond))
+	assert.Equal(t, queries["setcolortemperature"].Get("temperature"), "3800")
+	assert.Equal(t, queries["setcolortemperature"].Get("duration"), "5")
+
+	assert.NilError(t, f.SetLevelPercentage(ain, 75))
+	assert.Equal(t, queries["setlevelpercentage"].Get("level"), "75")
+
+	assert.Assert(t, errors.Is(f.SetColor(ain, 360, 0, 0), ErrOutOfRange))
+	assert.Assert(t, errors.Is(f.SetUnmappedColor(ain, 0, 256, 0), ErrOutOfRange))
+	assert.Assert(t, errors.Is(f.SetColorTemperature(ain, 3000, -time.Second), ErrOutOfRange))
+	assert.Assert(t, errors.Is(f.SetLevelPercentage(ain, 101), ErrOutOfRange))
+}
@@ -0,0 +1,155 @@
+package freepslib
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// these tests are meant to be run with the race detector: go test -race
+
+const concurrentRequests = 20
+
+func TestConcurrentRequestsShareLogin(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	box.respond("getswitchlist", "02361 0000734")
+	f := box.newFreeps(t, "secret")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, concurrentRequests)
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.HomeAutomation("getswitchlist", "", nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, box.loginCount(), 1)
+	assert.Equal(t, box.sessionCount(), 1)
+}
+
+func TestConcurrentReloginAfterExpiry(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	box.respond("getswitchstate", "1")
+	assert.NilError(t, f.Login())
+	staleSID := f.SID()
+	box.expireSessions()
+
+	// release all requests at once, so that they are all rejected with the stale SID
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			byt, err := f.HomeAutomation("getswitchstate", fmt.Sprintf("ain%v", i), nil)
+			assert.Check(t, err)
+			assert.Check(t, string(byt) == "1")
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	assert.Equal(t, box.loginCount(), 2)
+	assert.Assert(t, f.SID() != staleSID)
+}
+
+func TestConcurrentReloginWaiterSurvivesCancelledLeader(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	box.respond("getswitchlist", "02361 0000734")
+	f := box.newFreeps(t, "secret")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.HomeAutomationContext(ctx, "getswitchlist", "", nil)
+	assert.Assert(t, err != nil)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = f.HomeAutomationContext(ctx, "getswitchlist", "", nil)
+	assert.NilError(t, err)
+	assert.Equal(t, box.loginCount(), 1)
+}
+
+func TestConcurrentKeepAliveAndRequests(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	box.respond("getswitchlist", "02361 0000734")
+	f := box.newFreeps(t, "secret")
+	assert.NilError(t, f.Login())
+
+	f.StartKeepAlive(time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%5 == 0 {
+				box.expireSessions()
+			}
+			_, err := f.HomeAutomation("getswitchlist", "", nil)
+			assert.Check(t, err)
+			f.Session()
+			f.AuthScheme()
+		}(i)
+	}
+	wg.Wait()
+	f.StopKeepAlive()
+}
+
+// TestKeepAliveLoginCollidesWithRelogin holds the keepalive's login on the box
+// until the requests rejected with the same expired session want to log in too
+func TestKeepAliveLoginCollidesWithRelogin(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	box.respond("getswitchlist", "02361 0000734")
+	f := box.newFreeps(t, "secret")
+	assert.NilError(t, f.Login())
+	box.expireSessions()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	box.setLoginHook(func() {
+		once.Do(func() {
+			close(started)
+			<-release
+		})
+	})
+
+	f.StartKeepAlive(time.Millisecond)
+	<-started
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrentRequests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.HomeAutomation("getswitchlist", "", nil)
+			assert.Check(t, err)
+		}()
+	}
+	// wait until all requests were rejected, then give them time to ask for a login
+	for i := 0; i < 500 && box.rejectedCount() < concurrentRequests; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, box.rejectedCount(), concurrentRequests)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	f.StopKeepAlive()
+
+	assert.Equal(t, box.loginCount(), 2)
+	assert.Equal(t, box.sessionCount(), 1)
+}
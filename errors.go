@@ -0,0 +1,75 @@
+package freepslib
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hannesrauhe/freepslib/fritzbox_upnp"
+)
+
+var (
+	// ErrAuthFailed is returned if the FRITZ!Box rejects user or password, for the web interface as well as for UPnP
+	ErrAuthFailed = fritzbox_upnp.ErrAuthFailed
+	// ErrLoginBlocked is returned if the FRITZ!Box refuses logins after too many failed attempts, see LoginBlockedError
+	ErrLoginBlocked = errors.New("login blocked")
+	// ErrNoSession is returned if the session was closed, e.g. by a concurrent Logout, before it could be used
+	ErrNoSession = errors.New("no session")
+	// ErrForbidden is returned if the logged-in user lacks the right to call a function
+	ErrForbidden = fritzbox_upnp.ErrForbidden
+	// ErrDeviceNotFound is returned if there is no device with the given AIN
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrDeviceNotPresent is returned if the device is known, but not connected to the FRITZ!Box
+	ErrDeviceNotPresent = errors.New("device not present")
+	// ErrTemplateNotFound is returned if there is no template with the given identifier
+	ErrTemplateNotFound = errors.New("template not found")
+	// ErrInvalidValue is returned if the FRITZ!Box answers "inval", e.g. because the state of a device is unknown
+	ErrInvalidValue = errors.New("invalid or unknown value")
+	// ErrOutOfRange is returned if a parameter is outside of the range accepted by the device
+	ErrOutOfRange = errors.New("value out of range")
+	// ErrUnreachable is returned if the FRITZ!Box cannot be reached, see RequestError
+	ErrUnreachable = fritzbox_upnp.ErrUnreachable
+	// ErrPairingTimeout is returned if no new DECT device registered while the FRITZ!Box was waiting for one
+	ErrPairingTimeout = errors.New("device pairing timed out")
+	// ErrPairingFailed is returned if the FRITZ!Box aborted the registration of a new DECT device
+	ErrPairingFailed = errors.New("device pairing failed")
+)
+
+// ParseError is returned if a response cannot be decoded, it contains the raw body
+type ParseError = fritzbox_upnp.ParseError
+
+// UpnpFaultError is a SOAP fault returned by a UPnP action
+type UpnpFaultError = fritzbox_upnp.UpnpFaultError
+
+// RequestError is returned if a request did not get any response
+type RequestError = fritzbox_upnp.RequestError
+
+// HTTPStatusError is returned if the FRITZ!Box answers with an unexpected status code
+type HTTPStatusError = fritzbox_upnp.HTTPStatusError
+
+// LoginBlockedError is returned when the FRITZ!Box refuses logins after too many failed attempts
+type LoginBlockedError struct {
+	BlockTime time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("login blocked for %v seconds", int(e.BlockTime.Seconds()))
+}
+
+func (e *LoginBlockedError) Is(target error) bool {
+	return target == ErrLoginBlocked
+}
+
+// DeviceError is returned by functions that address a single device
+type DeviceError struct {
+	AIN string
+	Err error
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err, e.AIN)
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
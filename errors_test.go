@@ -0,0 +1,77 @@
+package freepslib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+
+	"github.com/hannesrauhe/freepslib/fritzbox_upnp"
+)
+
+func TestErrorsIsAs(t *testing.T) {
+	var err error = &LoginBlockedError{BlockTime: 8 * time.Second}
+	assert.Assert(t, errors.Is(err, ErrLoginBlocked))
+	var blocked *LoginBlockedError
+	assert.Assert(t, errors.As(err, &blocked))
+	assert.Equal(t, blocked.BlockTime, 8*time.Second)
+
+	err = &HTTPStatusError{StatusCode: http.StatusForbidden}
+	assert.Assert(t, errors.Is(err, ErrForbidden))
+	err = &HTTPStatusError{StatusCode: http.StatusInternalServerError}
+	assert.Assert(t, !errors.Is(err, ErrForbidden))
+
+	err = &RequestError{Err: context.DeadlineExceeded}
+	assert.Assert(t, errors.Is(err, ErrUnreachable))
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded))
+
+	err = &DeviceError{AIN: "12345 6789", Err: ErrDeviceNotFound}
+	assert.Assert(t, errors.Is(err, ErrDeviceNotFound))
+	assert.Error(t, err, "device not found: 12345 6789")
+
+	// errors of the UPnP package are the same types and values
+	assert.Assert(t, errors.Is(ErrAuthFailed, fritzbox_upnp.ErrAuthFailed))
+	err = &fritzbox_upnp.UpnpFaultError{Action: "GetInfo", FaultString: "UPnPError", ErrorCode: 401, ErrorDescription: "Invalid Action"}
+	var fault *UpnpFaultError
+	assert.Assert(t, errors.As(err, &fault))
+	assert.Equal(t, fault.ErrorCode, 401)
+}
+
+func TestParseErrorContainsBody(t *testing.T) {
+	_, err := parseDeviceList([]byte("<devicelist><device"))
+	var parseErr *ParseError
+	assert.Assert(t, errors.As(err, &parseErr))
+	assert.Equal(t, parseErr.Format, "XML")
+	assert.Equal(t, string(parseErr.Body), "<devicelist><device")
+}
+
+func TestErrorsFromFakeBox(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "wrong")
+	err := f.Login()
+	assert.Assert(t, errors.Is(err, ErrAuthFailed), err)
+
+	f = box.newFreeps(t, "secret")
+	// the box answers bad parameters and unknown AINs with 400 alike
+	_, err = f.HomeAutomation("getswitchstate", "12345 6789", nil)
+	assert.Assert(t, !errors.Is(err, ErrDeviceNotFound), err)
+	var devErr *DeviceError
+	assert.Assert(t, errors.As(err, &devErr), err)
+	assert.Equal(t, devErr.AIN, "12345 6789")
+	var statusErr *HTTPStatusError
+	assert.Assert(t, errors.As(err, &statusErr), err)
+	assert.Equal(t, statusErr.StatusCode, http.StatusBadRequest)
+
+	box.handleSwitch("getswitchlist", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	_, err = f.HomeAutomation("getswitchlist", "", nil)
+	assert.Assert(t, errors.Is(err, ErrForbidden), err)
+
+	box.Close()
+	_, err = f.HomeAutomation("getswitchlist", "", nil)
+	assert.Assert(t, errors.Is(err, ErrUnreachable), err)
+}
@@ -0,0 +1,157 @@
+package freepslib
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const fakeBoxChallenge = "2$10$5A1711$10$5A1722"
+
+// fakeBox is a minimal FRITZ!Box serving login_sid.lua and homeautoswitch.lua
+type fakeBox struct {
+	*httptest.Server
+	password string
+
+	mu       sync.Mutex
+	sids     map[string]bool
+	nextSid  int
+	logins   int
+	rejected int
+	switches map[string]func(w http.ResponseWriter, r *http.Request)
+	// loginHook is called before a login attempt is answered
+	loginHook func()
+}
+
+func newFakeBox(t *testing.T, password string) *fakeBox {
+	b := &fakeBox{
+		password: password,
+		sids:     map[string]bool{},
+		switches: map[string]func(w http.ResponseWriter, r *http.Request){},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login_sid.lua", b.handleLogin)
+	mux.HandleFunc("/webservices/homeautoswitch.lua", b.handleHomeAuto)
+	b.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(b.Close)
+	return b
+}
+
+func (b *fakeBox) newFreeps(t *testing.T, password string) *Freeps {
+	f, err := NewFreepsLib(&FBconfig{Address: b.Listener.Addr().String(), User: "freeps", Password: password})
+	assert.NilError(t, err)
+	return f
+}
+
+func (b *fakeBox) sessionInfo(w http.ResponseWriter, sid string) {
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><SessionInfo><SID>%v</SID><Challenge>%v</Challenge><BlockTime>0</BlockTime>`+
+		`<Rights><Name>HomeAuto</Name><Access>2</Access></Rights><Users><User last="1">freeps</User></Users></SessionInfo>`, sid, fakeBoxChallenge)
+}
+
+func (b *fakeBox) handleLogin(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	b.mu.Lock()
+	hook := b.loginHook
+	b.mu.Unlock()
+	if hook != nil && q.Get("response") != "" {
+		hook()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if sid := q.Get("sid"); sid != "" {
+		if q.Get("logout") == "1" || !b.sids[sid] {
+			delete(b.sids, sid)
+			b.sessionInfo(w, invalidSID)
+			return
+		}
+		b.sessionInfo(w, sid)
+		return
+	}
+	if response := q.Get("response"); response != "" {
+		expected, _ := calculatePbkdf2Response(fakeBoxChallenge, b.password)
+		if response != expected {
+			b.sessionInfo(w, invalidSID)
+			return
+		}
+		b.logins++
+		b.nextSid++
+		sid := fmt.Sprintf("%016x", b.nextSid)
+		b.sids[sid] = true
+		b.sessionInfo(w, sid)
+		return
+	}
+	b.sessionInfo(w, invalidSID)
+}
+
+func (b *fakeBox) handleHomeAuto(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	b.mu.Lock()
+	valid := b.sids[q.Get("sid")]
+	handler := b.switches[q.Get("switchcmd")]
+	if !valid {
+		b.rejected++
+	}
+	b.mu.Unlock()
+
+	if !valid {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if handler == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	handler(w, r)
+}
+
+// expireSessions simulates the FRITZ!Box dropping all sessions, e.g. after a timeout
+func (b *fakeBox) expireSessions() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sids = map[string]bool{}
+}
+
+func (b *fakeBox) handleSwitch(switchcmd string, handler func(w http.ResponseWriter, r *http.Request)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.switches[switchcmd] = handler
+}
+
+// respond registers a handler that always returns the given body for a switchcmd
+func (b *fakeBox) respond(switchcmd string, body string) {
+	b.handleSwitch(switchcmd, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.TrimSpace(body)+"\n")
+	})
+}
+
+func (b *fakeBox) setLoginHook(hook func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.loginHook = hook
+}
+
+// rejectedCount returns the number of home automation requests rejected because of an invalid SID
+func (b *fakeBox) rejectedCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.rejected
+}
+
+func (b *fakeBox) loginCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.logins
+}
+
+func (b *fakeBox) sessionCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.sids)
+}
@@ -2,8 +2,10 @@ package freepslib
 
 import (
 	"bytes"
+	"context"
+	"crypto/hmac"
 	"crypto/md5"
-	"crypto/tls"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
@@ -13,6 +15,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 	"unicode/utf16"
 
@@ -29,15 +34,37 @@ type FBconfig struct {
 	FB_address string `json:",omitempty"` // deprecated, use Address instead
 	FB_user    string `json:",omitempty"` // deprecated, use User instead
 	FB_pass    string `json:",omitempty"` // deprecated, use Password instead
+
+	Scheme              string `json:",omitempty"` // "https" (default) or "http"
+	Port                int    `json:",omitempty"` // e.g. the MyFRITZ port for remote access, default port of the scheme if unset
+	VerifyTLS           bool   `json:",omitempty"` // verify the certificate against the system roots
+	CACertFile          string `json:",omitempty"` // verify the certificate against the PEM bundle in this file
+	CertFingerprint     string `json:",omitempty"` // only accept the certificate with this SHA-256 fingerprint
+	CertFingerprintFile string `json:",omitempty"` // like CertFingerprint, the first certificate seen is trusted and stored here
+
+	Timeout time.Duration `json:",omitempty"` // for requests whose context has no deadline, DefaultTimeout if unset
+
+	HTTPClient *http.Client      `json:"-"` // used for all requests instead of a client built from the settings above
+	Transport  http.RoundTripper `json:"-"` // used instead of a transport built from the TLS settings above
 }
 
 var DefaultConfig = FBconfig{Address: "fritz.box", User: "freeps", Password: "password"}
 
+// Freeps is a client for a single FRITZ!Box, it is safe for concurrent use
 type Freeps struct {
-	conf          FBconfig
-	logger        logrus.FieldLogger
-	SID           string
-	metricsObject *fritzbox_upnp.Root
+	conf            FBconfig
+	logger          logrus.FieldLogger
+	client          *http.Client
+	sessionMu       sync.RWMutex
+	sid             string
+	authScheme      AuthScheme
+	session         *AvmSessionInfo
+	keepAliveCancel context.CancelFunc
+	pendingLogin    *loginCall
+	metricsMu       sync.Mutex
+	metricsObject   *fritzbox_upnp.Root
+	colorMu         sync.Mutex
+	colorLamps      map[string]colorLamp
 }
 
 func NewFreepsLib(conf *FBconfig) (*Freeps, error) {
@@ -82,38 +109,118 @@ func NewFreepsLibWithLogger(conf *FBconfig, logger logrus.FieldLogger) (*Freeps,
 			logger.Errorf("FB_pass and Password both set, using Password: %v", conf.Password)
 		}
 	}
-	f := &Freeps{conf: *conf, logger: logger}
+	client, err := newHttpClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	f := &Freeps{conf: *conf, logger: logger, client: client}
 	return f, nil
 }
 
-func (f *Freeps) login() error {
-	var err error
-
-	f.logger.Debugf("Trying to log into fritzbox")
+func (f *Freeps) getHttpClient() *http.Client {
+	return f.client
+}
 
-	f.SID, err = f.getSid()
+func (f *Freeps) httpGet(ctx context.Context, getURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getURL, nil)
 	if err != nil {
-		f.logger.Errorf("Failed to authenticate")
-		return err
+		return nil, redactSID(err)
 	}
-	return nil
+	resp, err := f.getHttpClient().Do(req)
+	return resp, redactSID(err)
 }
 
-func (f *Freeps) getHttpClient() *http.Client {
-	tr := &http.Transport{}
-	tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	return &http.Client{Transport: tr, Timeout: time.Second * 10}
+func (f *Freeps) httpPostForm(ctx context.Context, postURL string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, postURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return f.getHttpClient().Do(req)
 }
 
 /****** AUTH *****/
 
+// AvmRight is a permission a FRITZ!Box user can be granted
+type AvmRight string
+
+const (
+	RightNAS      AvmRight = "NAS"
+	RightApp      AvmRight = "App"
+	RightHomeAuto AvmRight = "HomeAuto"
+	RightBoxAdmin AvmRight = "BoxAdmin"
+	RightPhone    AvmRight = "Phone"
+	RightDial     AvmRight = "Dial"
+)
+
+// AvmAccessLevel is the level a right is granted with
+type AvmAccessLevel int
+
+const (
+	AccessNone      AvmAccessLevel = 0
+	AccessRead      AvmAccessLevel = 1
+	AccessReadWrite AvmAccessLevel = 2
+)
+
+// AvmRights contains the alternating <Name> and <Access> elements of the <Rights> element
+type AvmRights struct {
+	Name   []AvmRight       `xml:"Name"`
+	Access []AvmAccessLevel `xml:"Access"`
+}
+
+// AvmUser is a user that is allowed to log into the FRITZ!Box
+type AvmUser struct {
+	Name string `xml:",chardata"`
+	Last bool   `xml:"last,attr"` // the user that logged in most recently
+}
+
+// invalidSID is returned by the FRITZ!Box instead of a session ID if there is no valid session
+const invalidSID = "0000000000000000"
+
 type AvmSessionInfo struct {
 	SID       string
 	Challenge string
+	BlockTime int // seconds until the next login attempt is accepted
+	Rights    AvmRights
+	Users     []AvmUser `xml:"Users>User"`
+}
+
+// Access returns the level the right was granted with in this session
+func (s *AvmSessionInfo) Access(right AvmRight) AvmAccessLevel {
+	for i, name := range s.Rights.Name {
+		if name == right && i < len(s.Rights.Access) {
+			return s.Rights.Access[i]
+		}
+	}
+	return AccessNone
+}
+
+// HasRight returns true if the right was granted at least read access in this session
+func (s *AvmSessionInfo) HasRight(right AvmRight) bool {
+	return s.Access(right) >= AccessRead
+}
+
+// AuthScheme is the challenge-response scheme used to log into the FRITZ!Box
+type AuthScheme string
+
+const (
+	// AuthSchemeNone means no login has been attempted yet
+	AuthSchemeNone AuthScheme = ""
+	// AuthSchemeMD5 is the legacy MD5 challenge-response used by old firmwares
+	AuthSchemeMD5 AuthScheme = "md5"
+	// AuthSchemePBKDF2 is the PBKDF2-HMAC-SHA256 challenge-response introduced with FRITZ!OS 7.24
+	AuthSchemePBKDF2 AuthScheme = "pbkdf2"
+)
+
+// AuthScheme returns the scheme that was used for the most recent login
+func (f *Freeps) AuthScheme() AuthScheme {
+	f.sessionMu.RLock()
+	defer f.sessionMu.RUnlock()
+	return f.authScheme
 }
 
 func (f *Freeps) calculateChallengeURL(challenge string) string {
-	login_url := "https://" + f.conf.Address + "/login_sid.lua"
+	login_url := f.baseURL() + "/login_sid.lua"
 
 	// python: hashlib.md5('{}-{}'.format(challenge, password).encode('utf-16-le')).hexdigest()
 	u := utf16.Encode([]rune(challenge + "-" + f.conf.Password))
@@ -125,47 +232,132 @@ func (f *Freeps) calculateChallengeURL(challenge string) string {
 	h.Write(b)
 	chal_repsonse := hex.EncodeToString(h.Sum(nil))
 
-	return fmt.Sprintf("%v?username=%v&response=%v-%v", login_url, f.conf.User, challenge, chal_repsonse)
+	return fmt.Sprintf("%v?username=%v&response=%v-%v", login_url, url.QueryEscape(f.conf.User), challenge, chal_repsonse)
+}
+
+// pbkdf2Sha256 derives a single 32 byte block, which is all the FRITZ!Box needs
+func pbkdf2Sha256(password []byte, salt []byte, iterations int) []byte {
+	prf := hmac.New(sha256.New, password)
+	prf.Write(salt)
+	prf.Write([]byte{0, 0, 0, 1})
+	u := prf.Sum(nil)
+	t := make([]byte, len(u))
+	copy(t, u)
+	for i := 1; i < iterations; i++ {
+		prf.Reset()
+		prf.Write(u)
+		u = prf.Sum(u[:0])
+		for j := range t {
+			t[j] ^= u[j]
+		}
+	}
+	return t
 }
 
-func (f *Freeps) getSid() (string, error) {
-	login_url := "https://" + f.conf.Address + "/login_sid.lua"
-	client := f.getHttpClient()
-	// get Challenge:
-	first_resp, err := client.Get(login_url)
+// calculatePbkdf2Response answers a challenge of the form 2$<iter1>$<salt1>$<iter2>$<salt2>
+func calculatePbkdf2Response(challenge string, password string) (string, error) {
+	parts := strings.Split(challenge, "$")
+	if len(parts) != 5 || parts[0] != "2" {
+		return "", fmt.Errorf("invalid PBKDF2 challenge: %v", challenge)
+	}
+	iter1, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid PBKDF2 challenge: %v", challenge)
+	}
+	salt1, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid PBKDF2 challenge: %v", challenge)
+	}
+	iter2, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return "", fmt.Errorf("invalid PBKDF2 challenge: %v", challenge)
+	}
+	salt2, err := hex.DecodeString(parts[4])
+	if err != nil {
+		return "", fmt.Errorf("invalid PBKDF2 challenge: %v", challenge)
+	}
+
+	hash1 := pbkdf2Sha256([]byte(password), salt1, iter1)
+	hash2 := pbkdf2Sha256(hash1, salt2, iter2)
+	return parts[4] + "$" + hex.EncodeToString(hash2), nil
+}
+
+func (f *Freeps) calculatePbkdf2ChallengeURL(challenge string) (string, error) {
+	login_url := f.baseURL() + "/login_sid.lua"
+
+	chal_response, err := calculatePbkdf2Response(challenge, f.conf.Password)
 	if err != nil {
 		return "", err
 	}
+	return fmt.Sprintf("%v?version=2&username=%v&response=%v", login_url, url.QueryEscape(f.conf.User), chal_response), nil
+}
+
+func parseSessionInfo(byt []byte) (*AvmSessionInfo, error) {
+	var session AvmSessionInfo
+	err := xml.Unmarshal(byt, &session)
+	if err != nil {
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+	return &session, nil
+}
+
+func (f *Freeps) getSid(ctx context.Context) (*AvmSessionInfo, AuthScheme, error) {
+	login_url := f.baseURL() + "/login_sid.lua"
+	// get Challenge, boxes that do not know version 2 will just send an MD5 challenge
+	first_resp, err := f.httpGet(ctx, login_url+"?version=2")
+	if err != nil {
+		return nil, AuthSchemeNone, &RequestError{Err: err}
+	}
 	defer first_resp.Body.Close()
 
-	var unauth AvmSessionInfo
 	byt, err := io.ReadAll(first_resp.Body)
 	if err != nil {
-		return "", err
+		return nil, AuthSchemeNone, &RequestError{Err: err}
+	}
+	unauth, err := parseSessionInfo(byt)
+	if err != nil {
+		return nil, AuthSchemeNone, err
+	}
+	if unauth.BlockTime > 0 {
+		return nil, AuthSchemeNone, &LoginBlockedError{BlockTime: time.Duration(unauth.BlockTime) * time.Second}
 	}
-	xml.Unmarshal(byt, &unauth)
 
 	// respond to Challenge and get SID
-	second_resp, err := client.Get(f.calculateChallengeURL(unauth.Challenge))
+	var challengeURL string
+	var scheme AuthScheme
+	if strings.HasPrefix(unauth.Challenge, "2$") {
+		challengeURL, err = f.calculatePbkdf2ChallengeURL(unauth.Challenge)
+		if err != nil {
+			return nil, AuthSchemeNone, err
+		}
+		scheme = AuthSchemePBKDF2
+	} else {
+		f.logger.Debugf("FRITZ!Box does not support PBKDF2, falling back to MD5")
+		challengeURL = f.calculateChallengeURL(unauth.Challenge)
+		scheme = AuthSchemeMD5
+	}
+	second_resp, err := f.httpGet(ctx, challengeURL)
 	if err != nil {
-		return "", err
+		return nil, AuthSchemeNone, &RequestError{Err: err}
 	}
 	defer second_resp.Body.Close()
 
 	byt, err = io.ReadAll(second_resp.Body)
 	if err != nil {
-		return "", err
+		return nil, AuthSchemeNone, &RequestError{Err: err}
 	}
-	var authenticated AvmSessionInfo
-	err = xml.Unmarshal(byt, &authenticated)
+	authenticated, err := parseSessionInfo(byt)
 	if err != nil {
-		return "", err
+		return nil, AuthSchemeNone, err
 	}
-	if authenticated.SID == "0000000000000000" {
-		return "", errors.New("Authentication failed: wrong user/password")
+	if authenticated.SID == invalidSID {
+		if authenticated.BlockTime > 0 {
+			return nil, AuthSchemeNone, &LoginBlockedError{BlockTime: time.Duration(authenticated.BlockTime) * time.Second}
+		}
+		return nil, AuthSchemeNone, fmt.Errorf("%w: wrong user/password", ErrAuthFailed)
 	}
 
-	return authenticated.SID, nil
+	return authenticated, scheme, nil
 }
 
 /****** WebInterface functions *****/
@@ -187,30 +379,31 @@ type AvmDataResponse struct {
 	Data *AvmDataObject
 }
 
-func (f *Freeps) queryData(payload map[string]string, AvmResponse interface{}) error {
-	dataURL := "https://" + f.conf.Address + "/data.lua"
+func (f *Freeps) queryData(ctx context.Context, payload map[string]string, AvmResponse interface{}) error {
+	dataURL := f.baseURL() + "/data.lua"
 
 	// blindly try twice, because the first one might be an auth issue
 	for i := 0; i < 2; i++ {
+		sid := f.SID()
 		data := url.Values{}
-		data.Set("sid", f.SID)
+		data.Set("sid", sid)
 		for key, value := range payload {
 			data.Set(key, value)
 		}
 
-		dataResp, err := f.getHttpClient().PostForm(dataURL, data)
+		dataResp, err := f.httpPostForm(ctx, dataURL, data)
 		if err != nil {
-			return errors.New("cannot PostForm: " + err.Error())
+			return &RequestError{Err: err}
 		}
 		defer dataResp.Body.Close()
 
 		byt, err := io.ReadAll(dataResp.Body)
 		if err != nil {
-			return errors.New("cannot read response")
+			return &RequestError{Err: err}
 		}
 		if dataResp.StatusCode != 200 {
 			f.logger.Debugf("Unexpected http status: %v, Body:\n %v", dataResp.Status, byt)
-			return errors.New("http status code != 200")
+			return &HTTPStatusError{StatusCode: dataResp.StatusCode, Body: byt}
 		}
 
 		f.logger.Debugf("Received data:\n %q\n", byt)
@@ -220,10 +413,10 @@ func (f *Freeps) queryData(payload map[string]string, AvmResponse interface{}) e
 			return nil
 		}
 		if i > 0 {
-			return errors.New("cannot parse JSON response: " + err.Error())
+			return &ParseError{Format: "JSON", Body: byt, Err: err}
 		}
 
-		err = f.login()
+		err = f.relogin(ctx, sid)
 		if err != nil {
 			return err
 		}
@@ -233,6 +426,10 @@ func (f *Freeps) queryData(payload map[string]string, AvmResponse interface{}) e
 }
 
 func (f *Freeps) GetData() (*AvmDataResponse, error) {
+	return f.GetDataContext(context.Background())
+}
+
+func (f *Freeps) GetDataContext(ctx context.Context) (*AvmDataResponse, error) {
 	var avmResp *AvmDataResponse
 	var err error
 	payload := map[string]string{
@@ -240,7 +437,7 @@ func (f *Freeps) GetData() (*AvmDataResponse, error) {
 		"xhrId": "all",
 	}
 
-	err = f.queryData(payload, &avmResp)
+	err = f.queryData(ctx, payload, &avmResp)
 	return avmResp, err
 }
 
@@ -254,7 +451,11 @@ func getDeviceUID(fb_response AvmDataResponse, mac string) string {
 }
 
 func (f *Freeps) GetDeviceUID(mac string) (string, error) {
-	d, err := f.GetData()
+	return f.GetDeviceUIDContext(context.Background(), mac)
+}
+
+func (f *Freeps) GetDeviceUIDContext(ctx context.Context, mac string) (string, error) {
+	d, err := f.GetDataContext(ctx)
 
 	if err != nil {
 		return "", err
@@ -263,6 +464,10 @@ func (f *Freeps) GetDeviceUID(mac string) (string, error) {
 }
 
 func (f *Freeps) WakeUpDevice(uid string) error {
+	return f.WakeUpDeviceContext(context.Background(), uid)
+}
+
+func (f *Freeps) WakeUpDeviceContext(ctx context.Context, uid string) error {
 	var avmResp *AvmDataResponse
 	payload := map[string]string{
 		"dev":      uid,
@@ -271,7 +476,7 @@ func (f *Freeps) WakeUpDevice(uid string) error {
 		"btn_wake": "",
 	}
 
-	err := f.queryData(payload, &avmResp)
+	err := f.queryData(ctx, payload, &avmResp)
 	if err != nil {
 		return err
 	}
@@ -302,6 +507,10 @@ type AvmDeviceTemperature struct {
 	Offset  int `xml:"offset"`
 }
 
+type AvmDeviceHumidity struct {
+	RelHumidity int `xml:"rel_humidity"` // in %
+}
+
 type AvmDeviceSimpleonoff struct {
 	State bool `xml:"state"`
 }
@@ -311,10 +520,21 @@ type AvmDeviceLevelcontrol struct {
 	LevelPercentage float32 `xml:"levelpercentage"`
 }
 
+type AvmDeviceBlind struct {
+	EndPositionsSet bool   `xml:"endpositionsset"`
+	Mode            string `xml:"mode"` // "auto" or "manuell"
+}
+
 type AvmDeviceColorcontrol struct {
-	Hue         int `xml:"hue"`
-	Saturation  int `xml:"saturation"`
-	Temperature int `xml:"temperature"`
+	SupportedModes     AvmColorMode `xml:"supported_modes,attr"`
+	CurrentMode        AvmColorMode `xml:"current_mode,attr"`
+	FullColorSupport   bool         `xml:"fullcolorsupport,attr"`
+	Mapped             bool         `xml:"mapped,attr"`
+	Hue                int          `xml:"hue"`
+	Saturation         int          `xml:"saturation"`
+	UnmappedHue        int          `xml:"unmapped_hue"`
+	UnmappedSaturation int          `xml:"unmapped_saturation"`
+	Temperature        int          `xml:"temperature"`
 }
 
 type AvmNextChange struct {
@@ -337,6 +557,9 @@ type AvmDeviceHkr struct {
 	Summeractive            bool           `xml:"summeractive"`
 	Lock                    bool           `xml:"lock"`
 	Devicelock              bool           `xml:"devicelock"`
+	ErrorCode               int            `xml:"errorcode"` // 0 means no error, see HkrError* for the others
+	AdaptiveHeatingActive   bool           `xml:"adaptiveHeatingActive"`
+	AdaptiveHeatingRunning  bool           `xml:"adaptiveHeatingRunning"`
 	NextChange              *AvmNextChange `xml:"nextchange"`
 }
 
@@ -363,14 +586,20 @@ type AvmDevice struct {
 	AIN             string                 `xml:"identifier,attr"`
 	DeviceID        string                 `xml:"id,attr"`
 	ProductName     string                 `xml:"productname,attr" json:",omitempty"`
+	Manufacturer    string                 `xml:"manufacturer,attr" json:",omitempty"`
+	FwVersion       string                 `xml:"fwversion,attr" json:",omitempty"`
+	FunctionBitmask AvmFunctionBitmask     `xml:"functionbitmask,attr" json:",omitempty"`
 	Present         bool                   `xml:"present" json:",omitempty"`
+	TxBusy          bool                   `xml:"txbusy" json:",omitempty"`
 	Battery         *int                   `xml:"battery" json:",omitempty"`
 	BatteryLow      *bool                  `xml:"batterylow" json:",omitempty"`
 	Switch          *AvmDeviceSwitch       `xml:"switch" json:",omitempty"`
 	Temperature     *AvmDeviceTemperature  `xml:"temperature" json:",omitempty"`
+	Humidity        *AvmDeviceHumidity     `xml:"humidity" json:",omitempty"`
 	Powermeter      *AvmDevicePowermeter   `xml:"powermeter" json:",omitempty"`
 	SimpleOnOff     *AvmDeviceSimpleonoff  `xml:"simpleonoff" json:",omitempty"`
 	LevelControl    *AvmDeviceLevelcontrol `xml:"levelcontrol" json:",omitempty"`
+	Blind           *AvmDeviceBlind        `xml:"blind" json:",omitempty"`
 	ColorControl    *AvmDeviceColorcontrol `xml:"colorcontrol" json:",omitempty"`
 	HKR             *AvmDeviceHkr          `xml:"hkr" json:",omitempty"`
 	Alert           *AvmDeviceAlert        `xml:"alert" json:",omitempty"`
@@ -380,54 +609,94 @@ type AvmDevice struct {
 }
 
 type AvmDeviceList struct {
-	Device []AvmDevice `xml:"device"`
+	Version   string      `xml:"version,attr" json:",omitempty"`
+	FwVersion string      `xml:"fwversion,attr" json:",omitempty"`
+	Device    []AvmDevice `xml:"device"`
+	Groups    []AvmGroup  `xml:"group" json:",omitempty"`
 }
 
 type AvmTemplate struct {
-	Name       string         `xml:"name"`
-	Identifier string         `xml:"identifier,attr"`
-	ID         string         `xml:"id,attr"`
-	Devices    *AvmDeviceList `xml:"devices"`
+	Name            string               `xml:"name"`
+	Identifier      string               `xml:"identifier,attr"`
+	ID              string               `xml:"id,attr"`
+	FunctionBitmask AvmFunctionBitmask   `xml:"functionbitmask,attr" json:",omitempty"`
+	Devices         *AvmDeviceList       `xml:"devices"`
+	ApplyMask       AvmTemplateApplyMask `xml:"applymask"`
+	SubTemplates    []AvmTemplateRef     `xml:"sub_templates>template" json:",omitempty"`
+	Triggers        []AvmTemplateRef     `xml:"triggers>trigger" json:",omitempty"`
 }
 
 type AvmTemplateList struct {
 	Template []AvmTemplate `xml:"template"`
 }
 
-func (f *Freeps) queryHomeAutomation(switchcmd string, ain string, payload map[string]string) ([]byte, error) {
+// homeAutomationQuery returns the parameters of an AHA command, without the SID
+func homeAutomationQuery(switchcmd string, ain string, payload map[string]string) url.Values {
+	query := url.Values{}
+	query.Set("switchcmd", switchcmd)
+	if len(ain) > 0 {
+		query.Set("ain", ain)
+	}
+	for key, value := range payload {
+		query.Set(key, value)
+	}
+	return query
+}
+
+// encodeQuery encodes spaces as %20 instead of +, AINs like "13077 0013108-1" are documented that way
+func encodeQuery(query url.Values) string {
+	// a literal + has already been encoded as %2B at this point
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// redactSID removes the session ID from URLs in errors, so that it does not end up in logs
+func redactSID(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return err
+	}
+	query := u.Query()
+	if query.Has("sid") {
+		query.Set("sid", "REDACTED")
+		u.RawQuery = encodeQuery(query)
+		urlErr.URL = u.String()
+	}
+	return err
+}
+
+func (f *Freeps) queryHomeAutomation(ctx context.Context, switchcmd string, ain string, payload map[string]string) ([]byte, error) {
 	mTime := time.Now()
 
-	baseUrl := "https://" + f.conf.Address + "/webservices/homeautoswitch.lua"
-	var dataURL string
+	baseUrl := f.baseURL() + "/webservices/homeautoswitch.lua"
 	var dataResp *http.Response
 	var byt []byte
 	var err error
 	retry := true
 	for {
-		if len(ain) == 0 {
-			dataURL = fmt.Sprintf("%v?sid=%v&switchcmd=%v", baseUrl, f.SID, switchcmd)
-		} else {
-			dataURL = fmt.Sprintf("%v?sid=%v&switchcmd=%v&ain=%v", baseUrl, f.SID, switchcmd, ain)
-		}
-		for key, value := range payload {
-			dataURL += "&" + key + "=" + value
-		}
+		sid := f.SID()
+		query := homeAutomationQuery(switchcmd, ain, payload)
+		f.logger.Debugf("Sending %v", baseUrl+"?"+encodeQuery(query))
+		query.Set("sid", sid)
 
-		dataResp, err = f.getHttpClient().Get(dataURL)
+		dataResp, err = f.httpGet(ctx, baseUrl+"?"+encodeQuery(query))
 		if err != nil {
-			return nil, fmt.Errorf("Request to '%s' failed: %v", dataURL, err)
+			return nil, &RequestError{Err: err}
 		}
 		defer dataResp.Body.Close()
 
 		byt, err = io.ReadAll(dataResp.Body)
 		if err != nil {
-			return nil, errors.New("cannot read response")
+			return nil, &RequestError{Err: err}
 		}
 		if dataResp.StatusCode == 403 && retry {
 			retry = false
-			err = f.login()
+			err = f.relogin(ctx, sid)
 			if err != nil {
-				return nil, errors.New("failed to login: " + err.Error())
+				return nil, fmt.Errorf("failed to login: %w", err)
 			}
 			continue
 		}
@@ -436,7 +705,12 @@ func (f *Freeps) queryHomeAutomation(switchcmd string, ain string, payload map[s
 
 	if dataResp.StatusCode != 200 {
 		f.logger.Debugf("Unexpected http status: %v, Body:\n %q", dataResp.Status, byt)
-		return nil, errors.New("http status code != 200")
+		statusErr := &HTTPStatusError{StatusCode: dataResp.StatusCode, Body: byt}
+		if len(ain) > 0 {
+			// a bad request can mean an unknown AIN as well as any other bad parameter, so keep the status
+			return nil, &DeviceError{AIN: ain, Err: statusErr}
+		}
+		return nil, statusErr
 	}
 
 	time1 := time.Now().Unix() - mTime.Unix()
@@ -449,26 +723,49 @@ func parseDeviceList(byt []byte) (*AvmDeviceList, error) {
 	var avm_resp *AvmDeviceList
 	err := xml.Unmarshal(byt, &avm_resp)
 	if err != nil {
-		return nil, errors.New("cannot parse XML response")
-	}
-	// backward compatibility to old button handling
-	for i, dev := range avm_resp.Device {
-		if dev.ButtonFunctions != nil {
-			mostRecentPress := dev.ButtonFunctions[0].LastPressedTimestamp
-			for _, button := range dev.ButtonFunctions {
-				if button.LastPressedTimestamp > mostRecentPress {
-					mostRecentPress = button.LastPressedTimestamp
-				}
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+	for i := range avm_resp.Device {
+		avm_resp.Device[i].setOldButton()
+	}
+	avm_resp.resolveGroupMembers()
+
+	return avm_resp, nil
+}
+
+// setOldButton provides backward compatibility to old button handling
+func (d *AvmDevice) setOldButton() {
+	if d.ButtonFunctions != nil {
+		mostRecentPress := d.ButtonFunctions[0].LastPressedTimestamp
+		for _, button := range d.ButtonFunctions {
+			if button.LastPressedTimestamp > mostRecentPress {
+				mostRecentPress = button.LastPressedTimestamp
 			}
-			avm_resp.Device[i].Button = &AvmButton{LastPressedTimestamp: mostRecentPress}
 		}
+		d.Button = &AvmButton{LastPressedTimestamp: mostRecentPress}
 	}
+}
 
-	return avm_resp, nil
+// parseDevice parses the single device returned by getdeviceinfos
+func parseDevice(ain string, byt []byte) (*AvmDevice, error) {
+	if len(bytes.TrimSpace(byt)) == 0 {
+		return nil, &DeviceError{AIN: ain, Err: ErrDeviceNotFound}
+	}
+	var dev AvmDevice
+	err := xml.Unmarshal(byt, &dev)
+	if err != nil {
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+	dev.setOldButton()
+	return &dev, nil
 }
 
 func (f *Freeps) GetDeviceList() (*AvmDeviceList, error) {
-	byt, err := f.queryHomeAutomation("getdevicelistinfos",
+	return f.GetDeviceListContext(context.Background())
+}
+
+func (f *Freeps) GetDeviceListContext(ctx context.Context) (*AvmDeviceList, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getdevicelistinfos",
 		"", make(map[string]string))
 	if err != nil {
 		return nil, err
@@ -477,37 +774,65 @@ func (f *Freeps) GetDeviceList() (*AvmDeviceList, error) {
 	return parseDeviceList(byt)
 }
 
+// GetDevice returns a single device without downloading the whole device list, check Present
+// to find out whether it is connected
+func (f *Freeps) GetDevice(ain string) (*AvmDevice, error) {
+	return f.GetDeviceContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetDeviceContext(ctx context.Context, ain string) (*AvmDevice, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getdeviceinfos", ain, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	return parseDevice(ain, byt)
+}
+
 func (f *Freeps) GetTemplateList() (*AvmTemplateList, error) {
-	byt, err := f.queryHomeAutomation("gettemplatelistinfos",
+	return f.GetTemplateListContext(context.Background())
+}
+
+func (f *Freeps) GetTemplateListContext(ctx context.Context) (*AvmTemplateList, error) {
+	byt, err := f.queryHomeAutomation(ctx, "gettemplatelistinfos",
 		"", make(map[string]string))
 	if err != nil {
 		return nil, err
 	}
 
-	var avm_resp *AvmTemplateList
-	err = xml.Unmarshal(byt, &avm_resp)
+	tl, err := parseTemplateList(byt)
 	if err != nil {
 		f.logger.Debugf("Cannot parse XML: %q, err: %v", byt, err)
-		return nil, errors.New("cannot parse XML response")
+		return nil, err
 	}
-
-	return avm_resp, nil
+	return tl, nil
 }
 
 func (f *Freeps) HomeAutoSwitch(switchcmd string, ain string, payload map[string]string) error {
-	_, err := f.queryHomeAutomation(switchcmd, ain, payload)
+	return f.HomeAutoSwitchContext(context.Background(), switchcmd, ain, payload)
+}
+
+func (f *Freeps) HomeAutoSwitchContext(ctx context.Context, switchcmd string, ain string, payload map[string]string) error {
+	_, err := f.queryHomeAutomation(ctx, switchcmd, ain, payload)
 	return err
 }
 
 func (f *Freeps) HomeAutomation(switchcmd string, ain string, payload map[string]string) ([]byte, error) {
-	return f.queryHomeAutomation(switchcmd, ain, payload)
+	return f.HomeAutomationContext(context.Background(), switchcmd, ain, payload)
+}
+
+func (f *Freeps) HomeAutomationContext(ctx context.Context, switchcmd string, ain string, payload map[string]string) ([]byte, error) {
+	return f.queryHomeAutomation(ctx, switchcmd, ain, payload)
 }
 
 func (f *Freeps) SetLevel(ain string, level int) error {
+	return f.SetLevelContext(context.Background(), ain, level)
+}
+
+func (f *Freeps) SetLevelContext(ctx context.Context, ain string, level int) error {
 	payload := map[string]string{
 		"level": fmt.Sprint(level),
 	}
-	_, err := f.queryHomeAutomation("setlevel", ain, payload)
+	_, err := f.queryHomeAutomation(ctx, "setlevel", ain, payload)
 	return err
 }
 
@@ -535,14 +860,17 @@ var switchCmds map[string][]string = map[string][]string{
 	"getbasicdevicestats":  {"device"},
 	"gettemplatelistinfos": {""},
 	"applytemplate":        {"template"},
+	"gettriggerlistinfos":  {""},
+	"settriggeractive":     {"device", "active"},
 	"setsimpleonoff":       {"device", "onoff"},
 	"setlevel":             {"device", "level"},
 	"setlevelpercentage":   {"device", "level"},
 	"setcolor":             {"device", "hue", "saturation", "duration"},
 	"setcolortemperature":  {"device", "temperature", "duration"},
 	"getcolordefaults":     {"device"},
+	"setunmappedcolor":     {"device", "hue", "saturation", "duration"},
 	"sethkrboost":          {"device", "endtimestamp"},
-	"sethkrwindowopen":     {"device"},
+	"sethkrwindowopen":     {"device", "endtimestamp"},
 	"setblind":             {"device", "target"},
 	"setname":              {"device", "name"},
 	"startulesubscription": {"device"},
@@ -2,9 +2,19 @@ package freepslib
 
 import (
 	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"gotest.tools/v3/assert"
 )
 
@@ -23,6 +33,46 @@ func TestChallenge(t *testing.T) {
 	assert.Equal(t, f.calculateChallengeURL("a51eacbd"), expectedURL)
 }
 
+func TestChallengePbkdf2(t *testing.T) {
+	// example from AVM's "Session-IDs im FRITZ!Box Webinterface" technical note
+	response, err := calculatePbkdf2Response("2$10000$5A1711$2000$5A1722", "1example!")
+	assert.NilError(t, err)
+	assert.Equal(t, response, "5A1722$1798a1672bca7c6463d6b245f82b53703b0f50813401b03e4045a5861e689adb")
+
+	c := FBconfig{Address: "a", User: "u", Password: "1example!"}
+	f, err := NewFreepsLib(&c)
+	assert.NilError(t, err)
+	challengeURL, err := f.calculatePbkdf2ChallengeURL("2$10000$5A1711$2000$5A1722")
+	assert.NilError(t, err)
+	assert.Equal(t, challengeURL, "https://a/login_sid.lua?version=2&username=u&response=5A1722$1798a1672bca7c6463d6b245f82b53703b0f50813401b03e4045a5861e689adb")
+
+	_, err = calculatePbkdf2Response("2$10000$5A1711", "1example!")
+	assert.ErrorContains(t, err, "invalid PBKDF2 challenge")
+	_, err = calculatePbkdf2Response("2$abc$5A1711$2000$5A1722", "1example!")
+	assert.ErrorContains(t, err, "invalid PBKDF2 challenge")
+}
+
+func TestSessionInfoUnmarshal(t *testing.T) {
+	byteValue, err := os.ReadFile("./_testdata/test_sessioninfo.xml")
+	assert.NilError(t, err)
+
+	session, err := parseSessionInfo(byteValue)
+	assert.NilError(t, err)
+	assert.Equal(t, session.SID, "ff88e4d39354992f")
+	assert.Equal(t, session.BlockTime, 0)
+	assert.Equal(t, session.Access(RightHomeAuto), AccessReadWrite)
+	assert.Equal(t, session.Access(RightBoxAdmin), AccessRead)
+	assert.Assert(t, session.HasRight(RightBoxAdmin))
+	assert.Assert(t, !session.HasRight(RightNAS))
+	assert.Assert(t, !session.HasRight(AvmRight("unknown")))
+	assert.Equal(t, len(session.Users), 2)
+	assert.Equal(t, session.Users[0], AvmUser{Name: "freeps", Last: true})
+	assert.Equal(t, session.Users[1], AvmUser{Name: "admin", Last: false})
+
+	blocked := &LoginBlockedError{BlockTime: 32 * time.Second}
+	assert.Error(t, blocked, "login blocked for 32 seconds")
+}
+
 func TestGetUID(t *testing.T) {
 	t.SkipNow()
 	byteValue, err := os.ReadFile("./_testdata/test_data.json")
@@ -69,3 +119,173 @@ func TestDeviceListUnmarshal2(t *testing.T) {
 	// json.NewEncoder(newJsonFile).Encode(dlFromXML)
 	// assert.NilError(t, err)
 }
+
+// xmlPaths collects the element (a/b) and attribute (a/b@c) paths that t maps
+func xmlPaths(t reflect.Type, prefix string, paths map[string]bool) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("xml")
+		if field.Anonymous && tag == "" {
+			xmlPaths(field.Type, prefix, paths)
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		switch {
+		case name == "-" || opts == "chardata":
+		case opts == "attr":
+			paths[prefix+"@"+name] = true
+		default:
+			path := prefix + "/" + strings.ReplaceAll(name, ">", "/")
+			paths[path] = true
+			xmlPaths(field.Type, path, paths)
+		}
+	}
+}
+
+func TestDeviceListFullyMapped(t *testing.T) {
+	mapped := map[string]bool{}
+	xmlPaths(reflect.TypeOf(AvmDeviceList{}), "", mapped)
+
+	for _, fixture := range []string{"./_testdata/large_devicelist.xml", "./_testdata/test_devicelist.xml"} {
+		f, err := os.Open(fixture)
+		assert.NilError(t, err)
+		defer f.Close()
+
+		dec := xml.NewDecoder(f)
+		var stack []string
+		for {
+			tok, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			assert.NilError(t, err)
+			switch el := tok.(type) {
+			case xml.StartElement:
+				path := ""
+				if len(stack) > 0 {
+					path = stack[len(stack)-1] + "/" + el.Name.Local
+				}
+				stack = append(stack, path)
+				if path != "" {
+					assert.Check(t, mapped[path], "%v: element %v is not mapped", fixture, path)
+				}
+				for _, attr := range el.Attr {
+					assert.Check(t, mapped[path+"@"+attr.Name.Local], "%v: attribute %v@%v is not mapped", fixture, path, attr.Name.Local)
+				}
+			case xml.EndElement:
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+}
+
+func TestHomeAutomationQueryEncoding(t *testing.T) {
+	query := homeAutomationQuery("setname", "13077 0013108-1", map[string]string{"name": "Küche & Bad #1 50%+"})
+	assert.Equal(t, encodeQuery(query), "ain=13077%200013108-1&name=K%C3%BCche%20%26%20Bad%20%231%2050%25%2B&switchcmd=setname")
+}
+
+func TestHomeAutomationDebugLog(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	logger, hook := logtest.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	f, err := NewFreepsLibWithLogger(&FBconfig{Address: box.Listener.Addr().String(), User: "freeps", Password: "secret"}, logger)
+	assert.NilError(t, err)
+	box.respond("setname", "Küche\n")
+
+	_, err = f.HomeAutomation("setname", "13077 0013108-1", map[string]string{"name": "Küche"})
+	assert.NilError(t, err)
+	// the first request is rejected without a session and sent again after the login
+	sent := 0
+	for _, e := range hook.AllEntries() {
+		if strings.HasPrefix(e.Message, "Sending ") {
+			assert.Equal(t, e.Message, "Sending https://"+box.Listener.Addr().String()+"/webservices/homeautoswitch.lua?ain=13077%200013108-1&name=K%C3%BCche&switchcmd=setname")
+			sent++
+		}
+	}
+	assert.Equal(t, sent, 2)
+}
+
+func TestHomeAutomationRoundTrip(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	names := []string{"Küche & Bad", "Lampe #2", "50% = halb+halb", "a=b&switchcmd=setswitchoff", "  spaces  "}
+	for _, name := range names {
+		box.handleSwitch("setname", func(w http.ResponseWriter, r *http.Request) {
+			assert.Check(t, strings.Contains(r.URL.RawQuery, "ain=13077%200013108-1"), r.URL.RawQuery)
+			assert.Check(t, r.URL.Query().Get("ain") == "13077 0013108-1")
+			assert.Check(t, r.URL.Query().Get("switchcmd") == "setname")
+			fmt.Fprint(w, r.URL.Query().Get("name"))
+		})
+		byt, err := f.HomeAutomation("setname", "13077 0013108-1", map[string]string{"name": name})
+		assert.NilError(t, err)
+		assert.Equal(t, string(byt), name)
+	}
+}
+
+func TestSIDNotInErrors(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	assert.NilError(t, f.Login())
+	sid := f.SID()
+
+	box.Close()
+	_, err := f.HomeAutomation("getswitchlist", "", nil)
+	assert.Assert(t, err != nil)
+	assert.Assert(t, !strings.Contains(err.Error(), sid), err)
+	assert.Assert(t, strings.Contains(err.Error(), "sid=REDACTED"), err)
+}
+
+func TestChallengeURLEscapesUser(t *testing.T) {
+	c := FBconfig{Address: "a", User: "ü&x", Password: "p"}
+	f, err := NewFreepsLib(&c)
+	assert.NilError(t, err)
+	assert.Equal(t, f.calculateChallengeURL("a51eacbd"), "https://a/login_sid.lua?username=%C3%BC%26x&response=a51eacbd-05f2dd791db47141584e0f220b12c7e1")
+}
+
+const buttonDeviceXML = `<device identifier="13076 0019379" id="24" functionbitmask="32" fwversion="04.92" manufacturer="AVM" productname="FRITZ!DECT 400">
+<present>1</present><txbusy>0</txbusy><name>Taste 1</name><battery>50</battery><batterylow>0</batterylow>
+<button identifier="13076 0019379-0" id="5000"><name>Taste 1 kurz</name><lastpressedtimestamp>1741514032</lastpressedtimestamp></button>
+<button identifier="13076 0019379-9" id="5001"><name>Taste 1 lang</name><lastpressedtimestamp>1741514040</lastpressedtimestamp></button>
+</device>`
+
+func TestGetDevice(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.handleSwitch("getdeviceinfos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ain") != "13076 0019379" {
+			// the box answers an unknown AIN with an empty body
+			fmt.Fprint(w, "\n")
+			return
+		}
+		fmt.Fprint(w, buttonDeviceXML+"\n")
+	})
+
+	dev, err := f.GetDevice("13076 0019379")
+	assert.NilError(t, err)
+	assert.Equal(t, dev.Name, "Taste 1")
+	assert.Equal(t, len(dev.ButtonFunctions), 2)
+	assert.Assert(t, dev.Button != nil)
+	assert.Equal(t, dev.Button.LastPressedTimestamp, 1741514040)
+
+	_, err = f.GetDevice("13076 0019380")
+	assert.Assert(t, errors.Is(err, ErrDeviceNotFound))
+	var devErr *DeviceError
+	assert.Assert(t, errors.As(err, &devErr))
+	assert.Equal(t, devErr.AIN, "13076 0019380")
+
+	// a disconnected device is no error
+	dev, err = parseDevice("11630 ANON02", []byte(`<device identifier="11630 ANON02" id="26"><present>0</present><name>Outlet</name></device>`))
+	assert.NilError(t, err)
+	assert.Assert(t, !dev.Present)
+	assert.Equal(t, dev.Name, "Outlet")
+
+	_, err = parseDevice("13076 0019380", []byte("\n"))
+	assert.Assert(t, errors.Is(err, ErrDeviceNotFound))
+}
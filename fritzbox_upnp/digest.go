@@ -0,0 +1,101 @@
+package fritzbox_upnp
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// digestAuth keeps the state of HTTP Digest authentication for one device
+type digestAuth struct {
+	mu        sync.Mutex
+	realm     string
+	nonce     string
+	opaque    string
+	qop       string
+	algorithm string
+	nc        uint32 // number of requests sent with the current nonce
+}
+
+// parseDigestChallenge parses the parameters of a WWW-Authenticate header
+func parseDigestChallenge(wwwAuth string) (map[string]string, error) {
+	if !strings.HasPrefix(wwwAuth, "Digest ") {
+		return nil, fmt.Errorf("WWW-Authentication header is not Digest: '%s'", wwwAuth)
+	}
+
+	s := wwwAuth[7:]
+	d := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		d[strings.Trim(parts[0], "\" ")] = strings.Trim(parts[1], "\" ")
+	}
+
+	if d["algorithm"] == "" {
+		d["algorithm"] = "MD5"
+	} else if d["algorithm"] != "MD5" {
+		return nil, fmt.Errorf("digest algorithm not supported: %s != MD5", d["algorithm"])
+	}
+
+	if d["qop"] != "auth" {
+		return nil, fmt.Errorf("digest qop not supported: %s != auth", d["qop"])
+	}
+	return d, nil
+}
+
+// update stores a new challenge and resets the nonce count. It returns true if
+// the server marked the previous nonce as stale, i.e. the credentials were fine.
+func (da *digestAuth) update(wwwAuth string) (bool, error) {
+	d, err := parseDigestChallenge(wwwAuth)
+	if err != nil {
+		return false, err
+	}
+
+	da.mu.Lock()
+	defer da.mu.Unlock()
+	da.realm = d["realm"]
+	da.nonce = d["nonce"]
+	da.opaque = d["opaque"]
+	da.qop = d["qop"]
+	da.algorithm = d["algorithm"]
+	da.nc = 0
+	return strings.EqualFold(d["stale"], "true"), nil
+}
+
+// header calculates the Authorization header for the next request or returns
+// an empty string if no challenge was received yet
+func (da *digestAuth) header(method string, uri string, username string, password string) string {
+	da.mu.Lock()
+	if da.nonce == "" {
+		da.mu.Unlock()
+		return ""
+	}
+	da.nc++
+	nc := fmt.Sprintf("%08x", da.nc)
+	realm, nonce, opaque, qop, algorithm := da.realm, da.nonce, da.opaque, da.qop, da.algorithm
+	da.mu.Unlock()
+
+	// calc h1 and h2
+	ha1 := fmt.Sprintf("%x", md5.Sum([]byte(username+":"+realm+":"+password)))
+
+	ha2 := fmt.Sprintf("%x", md5.Sum([]byte(method+":"+uri)))
+
+	cn := make([]byte, 8)
+	rand.Read(cn)
+	cnonce := fmt.Sprintf("%x", cn)
+
+	ds := strings.Join([]string{ha1, nonce, nc, cnonce, qop, ha2}, ":")
+	response := fmt.Sprintf("%x", md5.Sum([]byte(ds)))
+
+	authHeader := fmt.Sprintf("Digest username=\"%s\", realm=\"%s\", nonce=\"%s\", uri=\"%s\", cnonce=\"%s\", nc=%s, qop=%s, response=\"%s\", algorithm=%s",
+		username, realm, nonce, uri, cnonce, nc, qop, response, algorithm)
+	if opaque != "" {
+		authHeader += fmt.Sprintf(", opaque=\"%s\"", opaque)
+	}
+
+	return authHeader
+}
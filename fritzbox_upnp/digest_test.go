@@ -0,0 +1,94 @@
+package fritzbox_upnp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeDigestDevice accepts every Authorization header with the current nonce and records nonce counts
+type fakeDigestDevice struct {
+	mu       sync.Mutex
+	nonce    int
+	staleNow bool
+	ncs      []string
+}
+
+func (d *fakeDigestDevice) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	auth, err := parseDigestChallenge(r.Header.Get("Authorization"))
+	if err != nil || auth["nonce"] != fmt.Sprint(d.nonce) || d.staleNow {
+		stale := ""
+		if d.staleNow {
+			d.staleNow = false
+			d.nonce++
+			stale = ", stale=true"
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="F!Box SOAP-Auth", nonce="%v", algorithm=MD5, qop="auth"%v`, d.nonce, stale))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	d.ncs = append(d.ncs, auth["nc"])
+	w.Header().Set("Content-Type", textXML)
+}
+
+func newTestAction(root *Root) *Action {
+	svc := &Service{Device: &Device{root: root}, ServiceType: "urn:dslforum-org:service:DeviceInfo:1", ControlURL: "/upnp/control/deviceinfo"}
+	return &Action{service: svc, Name: "GetInfo", ArgumentMap: map[string]*Argument{}}
+}
+
+func TestDigestNonceCountAndStale(t *testing.T) {
+	device := &fakeDigestDevice{}
+	server := httptest.NewServer(device)
+	defer server.Close()
+
+	root := &Root{BaseURL: server.URL, Username: "u", Password: "p", client: server.Client(), auth: &digestAuth{}}
+	action := newTestAction(root)
+
+	for i := 0; i < 3; i++ {
+		_, err := action.Call(nil)
+		assert.NilError(t, err)
+	}
+	assert.DeepEqual(t, device.ncs, []string{"00000001", "00000002", "00000003"})
+
+	device.staleNow = true
+	_, err := action.Call(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, device.ncs[3], "00000001")
+}
+
+func TestDigestAuthIsPerRoot(t *testing.T) {
+	device := &fakeDigestDevice{}
+	server := httptest.NewServer(device)
+	defer server.Close()
+
+	root1 := &Root{BaseURL: server.URL, Username: "u", Password: "p", client: server.Client(), auth: &digestAuth{}}
+	root2 := &Root{BaseURL: server.URL, Username: "u", Password: "p", client: server.Client(), auth: &digestAuth{}}
+
+	_, err := newTestAction(root1).Call(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, root1.auth.nc, uint32(1))
+	assert.Equal(t, root2.auth.nonce, "")
+}
+
+func TestDigestWrongPassword(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Digest realm="F!Box SOAP-Auth", nonce="1", algorithm=MD5, qop="auth"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	root := &Root{BaseURL: server.URL, Username: "u", Password: "wrong", client: server.Client(), auth: &digestAuth{}}
+	_, err := newTestAction(root).Call(nil)
+	assert.ErrorContains(t, err, "wrong username or password")
+
+	root.Password = ""
+	_, err = newTestAction(root).Call(nil)
+	assert.ErrorContains(t, err, "no username and password given")
+}
@@ -0,0 +1,78 @@
+package fritzbox_upnp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrAuthFailed is returned if the device rejects the credentials
+	ErrAuthFailed = errors.New("authentication failed")
+	// ErrForbidden is returned if the user lacks the right to call a function
+	ErrForbidden = errors.New("forbidden")
+	// ErrUnreachable is returned if the device cannot be reached, see RequestError
+	ErrUnreachable = errors.New("FRITZ!Box unreachable")
+)
+
+// UpnpFaultError is a SOAP fault returned by an action
+type UpnpFaultError struct {
+	Action           string
+	FaultCode        string
+	FaultString      string
+	ErrorCode        int    // only set for UPnPError faults
+	ErrorDescription string // only set for UPnPError faults
+}
+
+func (e *UpnpFaultError) Error() string {
+	if e.FaultString == "UPnPError" {
+		return fmt.Sprintf("%s: SOAPFault: %s %d (%s)", e.Action, e.FaultString, e.ErrorCode, e.ErrorDescription)
+	}
+	return fmt.Sprintf("%s: SOAPFault: %s", e.Action, e.FaultString)
+}
+
+// ParseError is returned if a response cannot be decoded, it contains the raw body
+type ParseError struct {
+	Format string // XML, JSON or plain text
+	Body   []byte
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("cannot parse %s response: %v", e.Format, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// RequestError is returned if a request did not get any response
+type RequestError struct {
+	Err error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrUnreachable, e.Err)
+}
+
+func (e *RequestError) Is(target error) bool {
+	return target == ErrUnreachable
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// HTTPStatusError is returned if the device answers with an unexpected status code
+type HTTPStatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("http status code %v != 200", e.StatusCode)
+}
+
+func (e *HTTPStatusError) Is(target error) bool {
+	return target == ErrForbidden && e.StatusCode == http.StatusForbidden
+}
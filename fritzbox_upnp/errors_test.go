@@ -0,0 +1,42 @@
+package fritzbox_upnp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCallErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	root := &Root{BaseURL: server.URL, client: server.Client(), auth: &digestAuth{}}
+
+	_, err := newTestAction(root).Call(nil)
+	assert.Assert(t, errors.Is(err, ErrForbidden), err)
+	var statusErr *HTTPStatusError
+	assert.Assert(t, errors.As(err, &statusErr))
+	assert.Equal(t, statusErr.StatusCode, http.StatusForbidden)
+
+	server.Close()
+	_, err = newTestAction(root).Call(nil)
+	assert.Assert(t, errors.Is(err, ErrUnreachable), err)
+	var requestErr *RequestError
+	assert.Assert(t, errors.As(err, &requestErr))
+}
+
+func TestLoadServicesErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	_, err := LoadServicesWithClient(context.Background(), server.URL, "", "", server.Client())
+	var statusErr *HTTPStatusError
+	assert.Assert(t, errors.As(err, &statusErr), err)
+	assert.Equal(t, statusErr.StatusCode, http.StatusNotFound)
+
+	server.Close()
+	_, err = LoadServicesWithClient(context.Background(), server.URL, "", "", server.Client())
+	assert.Assert(t, errors.Is(err, ErrUnreachable), err)
+}
@@ -17,8 +17,7 @@ package fritzbox_upnp
 
 import (
 	"bytes"
-	"crypto/md5"
-	"crypto/rand"
+	"context"
 	"crypto/tls"
 	"encoding/xml"
 	"errors"
@@ -47,6 +46,9 @@ type Root struct {
 	Password string
 	Device   Device              `xml:"device"`
 	Services map[string]*Service // Map of all services indexed by .ServiceType
+
+	client *http.Client
+	auth   *digestAuth
 }
 
 // Device an UPNP device
@@ -165,69 +167,62 @@ type StateVariable struct {
 // The type of the value is string, uint64 or bool depending of the DataType of the variable.
 type Result map[string]interface{}
 
-// load the whole tree
-func (r *Root) load() error {
-	igddesc, err := http.Get(
-		fmt.Sprintf("%s/igddesc.xml", r.BaseURL),
-	)
-
+// getXML downloads url and decodes the XML document into v
+func (r *Root) getXML(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
-
-	defer igddesc.Body.Close()
-
-	dec := xml.NewDecoder(igddesc.Body)
-
-	err = dec.Decode(r)
+	resp, err := r.client.Do(req)
 	if err != nil {
-		return err
+		return &RequestError{Err: err}
 	}
+	defer resp.Body.Close()
 
-	r.Services = make(map[string]*Service)
-	return r.Device.fillServices(r)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &RequestError{Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Body: body}
+	}
+	err = xml.Unmarshal(body, v)
+	if err != nil {
+		return &ParseError{Format: "XML", Body: body, Err: err}
+	}
+	return nil
 }
 
-func (r *Root) loadTr64() error {
-	igddesc, err := http.Get(
-		fmt.Sprintf("%s/tr64desc.xml", r.BaseURL),
-	)
-
+// load the whole tree
+func (r *Root) load(ctx context.Context) error {
+	err := r.getXML(ctx, fmt.Sprintf("%s/igddesc.xml", r.BaseURL), r)
 	if err != nil {
 		return err
 	}
 
-	defer igddesc.Body.Close()
-
-	dec := xml.NewDecoder(igddesc.Body)
+	r.Services = make(map[string]*Service)
+	return r.Device.fillServices(ctx, r)
+}
 
-	err = dec.Decode(r)
+func (r *Root) loadTr64(ctx context.Context) error {
+	err := r.getXML(ctx, fmt.Sprintf("%s/tr64desc.xml", r.BaseURL), r)
 	if err != nil {
 		return err
 	}
 
 	r.Services = make(map[string]*Service)
-	return r.Device.fillServices(r)
+	return r.Device.fillServices(ctx, r)
 }
 
 // load all service descriptions
-func (d *Device) fillServices(r *Root) error {
+func (d *Device) fillServices(ctx context.Context, r *Root) error {
 	d.root = r
 
 	for _, s := range d.Services {
 		s.Device = d
 
-		response, err := http.Get(r.BaseURL + s.SCPDUrl)
-		if err != nil {
-			return err
-		}
-
-		defer response.Body.Close()
-
 		var scpd scpdRoot
-
-		dec := xml.NewDecoder(response.Body)
-		err = dec.Decode(&scpd)
+		err := r.getXML(ctx, r.BaseURL+s.SCPDUrl, &scpd)
 		if err != nil {
 			return err
 		}
@@ -256,7 +251,7 @@ func (d *Device) fillServices(r *Root) error {
 		r.Services[s.ServiceType] = s
 	}
 	for _, d2 := range d.Devices {
-		err := d2.fillServices(r)
+		err := d2.fillServices(ctx, r)
 		if err != nil {
 			return err
 		}
@@ -271,7 +266,7 @@ const soapActionXML = `<?xml version="1.0" encoding="utf-8"?>` +
 
 const soapActionParamXML = `<%s>%s</%s>`
 
-func (a *Action) createCallHTTPRequest(actionArg *ActionArgument) (*http.Request, error) {
+func (a *Action) createCallHTTPRequest(ctx context.Context, actionArg *ActionArgument) (*http.Request, error) {
 	argsString := ""
 	if actionArg != nil {
 		var buf bytes.Buffer
@@ -284,7 +279,7 @@ func (a *Action) createCallHTTPRequest(actionArg *ActionArgument) (*http.Request
 	url := a.service.Device.root.BaseURL + a.service.ControlURL
 	body := strings.NewReader(bodystr)
 
-	req, err := http.NewRequest("POST", url, body)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -297,135 +292,87 @@ func (a *Action) createCallHTTPRequest(actionArg *ActionArgument) (*http.Request
 	return req, nil
 }
 
-// store auth header for reuse
-var authHeader = ""
-
 // Call an action with argument if given
 func (a *Action) Call(actionArg *ActionArgument) (Result, error) {
-	req, err := a.createCallHTTPRequest(actionArg)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// reuse prior authHeader, to avoid unnecessary authentication
-	if authHeader != "" {
-		req.Header.Set("Authorization", authHeader)
-	}
-
-	// first try call without auth header
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		return nil, err
-	}
+	return a.CallContext(context.Background(), actionArg)
+}
 
-	wwwAuth := resp.Header.Get("WWW-Authenticate")
-	if resp.StatusCode == http.StatusUnauthorized {
-		resp.Body.Close() // close now, since we make a new request below or fail
+// CallContext calls an action with argument if given, the context is used for all requests
+func (a *Action) CallContext(ctx context.Context, actionArg *ActionArgument) (Result, error) {
+	root := a.service.Device.root
 
-		if wwwAuth != "" && a.service.Device.root.Username != "" && a.service.Device.root.Password != "" {
-			// call failed, but we have a password so calculate header and try again
-			authHeader, err = a.getDigestAuthHeader(wwwAuth, a.service.Device.root.Username, a.service.Device.root.Password)
-			if err != nil {
-				return nil, fmt.Errorf("%s: %s", a.Name, err.Error())
-			}
-
-			req, err = a.createCallHTTPRequest(actionArg)
-			if err != nil {
-				return nil, fmt.Errorf("%s: %s", a.Name, err.Error())
-			}
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		req, err := a.createCallHTTPRequest(ctx, actionArg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", a.Name, err.Error())
+		}
 
+		// reuse prior challenge, to avoid unnecessary authentication
+		authHeader := root.auth.header("POST", a.service.ControlURL, root.Username, root.Password)
+		if authHeader != "" {
 			req.Header.Set("Authorization", authHeader)
+		}
 
-			resp, err = http.DefaultClient.Do(req)
+		resp, err = root.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.Name, &RequestError{Err: err})
+		}
+		if resp.StatusCode != http.StatusUnauthorized {
+			break
+		}
+		resp.Body.Close() // close now, since we make a new request below or fail
 
-			if err != nil {
-				return nil, fmt.Errorf("%s: %s", a.Name, err.Error())
-			}
+		wwwAuth := resp.Header.Get("WWW-Authenticate")
+		if wwwAuth == "" || root.Username == "" || root.Password == "" {
+			return nil, fmt.Errorf("%s: %w: Unauthorized, but no username and password given", a.Name, ErrAuthFailed)
+		}
 
-		} else {
-			return nil, fmt.Errorf("%s: Unauthorized, but no username and password given", a.Name)
+		// call failed, but we have a password so store the new challenge and try again
+		stale, err := root.auth.update(wwwAuth)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", a.Name, err.Error())
+		}
+		// a stale nonce is answered once more, anything else means the credentials are wrong
+		if (attempt > 0 && !stale) || attempt > 1 {
+			return nil, fmt.Errorf("%s: %w: wrong username or password", a.Name, ErrAuthFailed)
 		}
 	}
 
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		errMsg := fmt.Sprintf("%s (%d)", http.StatusText(resp.StatusCode), resp.StatusCode)
-		if resp.StatusCode == 500 {
-			buf := new(strings.Builder)
-			io.Copy(buf, resp.Body)
-			body := buf.String()
-			//fmt.Println(body)
-
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", a.Name, &RequestError{Err: err})
+		}
+		if resp.StatusCode == http.StatusInternalServerError {
 			var soapEnv SoapEnvelope
-			err := xml.Unmarshal([]byte(body), &soapEnv)
+			err = xml.Unmarshal(body, &soapEnv)
 			if err != nil {
-				errMsg = fmt.Sprintf("error decoding SOAPFault: %s", err.Error())
-			} else {
-				soapFault := soapEnv.Body.Fault
-
-				if soapFault.FaultString == "UPnPError" {
-					upe := soapFault.Detail.UpnpError
-
-					errMsg = fmt.Sprintf("SAOPFault: %s %d (%s)", soapFault.FaultString, upe.ErrorCode, upe.ErrorDescription)
-				} else {
-					errMsg = fmt.Sprintf("SAOPFault: %s", soapFault.FaultString)
-				}
+				return nil, fmt.Errorf("%s: error decoding SOAPFault: %w", a.Name, &ParseError{Format: "XML", Body: body, Err: err})
+			}
+			soapFault := soapEnv.Body.Fault
+			return nil, &UpnpFaultError{
+				Action:           a.Name,
+				FaultCode:        soapFault.FaultCode,
+				FaultString:      soapFault.FaultString,
+				ErrorCode:        soapFault.Detail.UpnpError.ErrorCode,
+				ErrorDescription: soapFault.Detail.UpnpError.ErrorDescription,
 			}
 		}
-		return nil, fmt.Errorf("%s: %s", a.Name, errMsg)
-	}
-
-	return a.parseSoapResponse(resp.Body)
-}
-
-func (a *Action) getDigestAuthHeader(wwwAuth string, username string, password string) (string, error) {
-	// parse www-auth header
-	if !strings.HasPrefix(wwwAuth, "Digest ") {
-		return "", fmt.Errorf("WWW-Authentication header is not Digest: '%s'", wwwAuth)
-	}
-
-	s := wwwAuth[7:]
-	d := map[string]string{}
-	for _, kv := range strings.Split(s, ",") {
-		parts := strings.SplitN(kv, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		d[strings.Trim(parts[0], "\" ")] = strings.Trim(parts[1], "\" ")
+		return nil, fmt.Errorf("%s: %w", a.Name, &HTTPStatusError{StatusCode: resp.StatusCode, Body: body})
 	}
 
-	if d["algorithm"] == "" {
-		d["algorithm"] = "MD5"
-	} else if d["algorithm"] != "MD5" {
-		return "", fmt.Errorf("digest algorithm not supported: %s != MD5", d["algorithm"])
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.Name, &RequestError{Err: err})
 	}
-
-	if d["qop"] != "auth" {
-		return "", fmt.Errorf("digest qop not supported: %s != auth", d["qop"])
+	res, err := a.parseSoapResponse(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.Name, &ParseError{Format: "SOAP", Body: body, Err: err})
 	}
-
-	// calc h1 and h2
-	ha1 := fmt.Sprintf("%x", md5.Sum([]byte(username+":"+d["realm"]+":"+password)))
-
-	ha2 := fmt.Sprintf("%x", md5.Sum([]byte("POST:"+a.service.ControlURL)))
-
-	cn := make([]byte, 8)
-	rand.Read(cn)
-	cnonce := fmt.Sprintf("%x", cn)
-
-	nCounter := 1
-	nc := fmt.Sprintf("%08x", nCounter)
-
-	ds := strings.Join([]string{ha1, d["nonce"], nc, cnonce, d["qop"], ha2}, ":")
-	response := fmt.Sprintf("%x", md5.Sum([]byte(ds)))
-
-	authHeader := fmt.Sprintf("Digest username=\"%s\", realm=\"%s\", nonce=\"%s\", uri=\"%s\", cnonce=\"%s\", nc=%s, qop=%s, response=\"%s\", algorithm=%s",
-		username, d["realm"], d["nonce"], a.service.ControlURL, cnonce, nc, d["qop"], response, d["algorithm"])
-
-	return authHeader, nil
+	return res, nil
 }
 
 func (a *Action) parseSoapResponse(r io.Reader) (Result, error) {
@@ -502,19 +449,33 @@ func convertResult(val string, arg *Argument) (interface{}, error) {
 
 // LoadServices loads the services tree from an device.
 func LoadServices(baseurl string, username string, password string, verifyTls bool) (*Root, error) {
+	return LoadServicesContext(context.Background(), baseurl, username, password, verifyTls)
+}
 
+// LoadServicesContext loads the services tree from an device, the context is used for all requests.
+func LoadServicesContext(ctx context.Context, baseurl string, username string, password string, verifyTls bool) (*Root, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
 	if !verifyTls && strings.HasPrefix(baseurl, "https://") {
 		// disable certificate validation, since fritz.box uses self signed cert
-		http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
+	return LoadServicesWithClient(ctx, baseurl, username, password, &http.Client{Transport: tr})
+}
+
+// LoadServicesWithClient loads the services tree from an device using the given client for all requests.
+func LoadServicesWithClient(ctx context.Context, baseurl string, username string, password string, client *http.Client) (*Root, error) {
+	// both trees belong to the same device, so they share the authentication state
+	auth := &digestAuth{}
 
 	var root = &Root{
 		BaseURL:  baseurl,
 		Username: username,
 		Password: password,
+		client:   client,
+		auth:     auth,
 	}
 
-	err := root.load()
+	err := root.load(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -523,9 +484,11 @@ func LoadServices(baseurl string, username string, password string, verifyTls bo
 		BaseURL:  baseurl,
 		Username: username,
 		Password: password,
+		client:   client,
+		auth:     auth,
 	}
 
-	err = rootTr64.loadTr64()
+	err = rootTr64.loadTr64(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,92 @@
+package freepslib
+
+import (
+	"context"
+	"strings"
+)
+
+type AvmGroupInfo struct {
+	MasterDeviceID string `xml:"masterdeviceid"` // "0" if there is no master device
+	Members        string `xml:"members"`        // comma-separated ids of the member devices
+}
+
+// AvmGroup is a group of devices, it can be switched like a single device using its AIN
+type AvmGroup struct {
+	AvmDevice
+	GroupInfo     *AvmGroupInfo `xml:"groupinfo" json:",omitempty"`
+	MemberDevices []*AvmDevice  `xml:"-" json:"-"` // resolved from GroupInfo.Members by parseDeviceList
+}
+
+// MemberIDs returns the ids of all devices in the group
+func (g *AvmGroup) MemberIDs() []string {
+	if g.GroupInfo == nil || g.GroupInfo.Members == "" {
+		return []string{}
+	}
+	ids := strings.Split(g.GroupInfo.Members, ",")
+	for i := range ids {
+		ids[i] = strings.TrimSpace(ids[i])
+	}
+	return ids
+}
+
+// resolveGroupMembers points the members of every group to the devices in the list
+func (dl *AvmDeviceList) resolveGroupMembers() {
+	byID := make(map[string]*AvmDevice, len(dl.Device))
+	for i := range dl.Device {
+		byID[dl.Device[i].DeviceID] = &dl.Device[i]
+	}
+	for i := range dl.Groups {
+		group := &dl.Groups[i]
+		group.MemberDevices = make([]*AvmDevice, 0)
+		for _, id := range group.MemberIDs() {
+			if dev, ok := byID[id]; ok {
+				group.MemberDevices = append(group.MemberDevices, dev)
+			}
+		}
+	}
+}
+
+// GetGroup returns the group with the given AIN or nil
+func (dl *AvmDeviceList) GetGroup(ain string) *AvmGroup {
+	for i := range dl.Groups {
+		if dl.Groups[i].AIN == ain {
+			return &dl.Groups[i]
+		}
+	}
+	return nil
+}
+
+func (f *Freeps) SwitchGroupOn(group *AvmGroup) error {
+	return f.switchGroup(context.Background(), group, "1")
+}
+
+func (f *Freeps) SwitchGroupOnContext(ctx context.Context, group *AvmGroup) error {
+	return f.switchGroup(ctx, group, "1")
+}
+
+func (f *Freeps) SwitchGroupOff(group *AvmGroup) error {
+	return f.switchGroup(context.Background(), group, "0")
+}
+
+func (f *Freeps) SwitchGroupOffContext(ctx context.Context, group *AvmGroup) error {
+	return f.switchGroup(ctx, group, "0")
+}
+
+func (f *Freeps) SwitchGroupToggle(group *AvmGroup) error {
+	return f.switchGroup(context.Background(), group, "2")
+}
+
+func (f *Freeps) SwitchGroupToggleContext(ctx context.Context, group *AvmGroup) error {
+	return f.switchGroup(ctx, group, "2")
+}
+
+// switchGroup uses setsimpleonoff for groups of lights and setswitch* for groups of outlets
+func (f *Freeps) switchGroup(ctx context.Context, group *AvmGroup, onoff string) error {
+	if group.SimpleOnOff != nil {
+		_, err := f.queryHomeAutomation(ctx, "setsimpleonoff", group.AIN, map[string]string{"onoff": onoff})
+		return err
+	}
+	switchcmd := map[string]string{"0": "setswitchoff", "1": "setswitchon", "2": "setswitchtoggle"}[onoff]
+	_, err := f.queryHomeAutomation(ctx, switchcmd, group.AIN, map[string]string{})
+	return err
+}
@@ -0,0 +1,73 @@
+package freepslib
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestGroupUnmarshal(t *testing.T) {
+	byteValue, err := os.ReadFile("./_testdata/test_devicelist.xml")
+	assert.NilError(t, err)
+
+	dl, err := parseDeviceList(byteValue)
+	assert.NilError(t, err)
+	assert.Equal(t, len(dl.Groups), 1)
+
+	group := dl.GetGroup("65:1A:11-900")
+	assert.Assert(t, group != nil)
+	assert.Equal(t, group.Name, "Gruppe")
+	assert.Equal(t, group.DeviceID, "900")
+	assert.Assert(t, group.Present)
+	assert.Assert(t, group.Switch != nil)
+	assert.Assert(t, group.Switch.State)
+	assert.Equal(t, group.GroupInfo.MasterDeviceID, "0")
+	assert.DeepEqual(t, group.MemberIDs(), []string{"17"})
+	assert.Equal(t, len(group.MemberDevices), 1)
+	assert.Assert(t, group.MemberDevices[0] == &dl.Device[0])
+	assert.Equal(t, group.MemberDevices[0].Name, "Steckdose")
+
+	assert.Assert(t, dl.GetGroup("unknown") == nil)
+}
+
+func TestGroupMembers(t *testing.T) {
+	group := AvmGroup{GroupInfo: &AvmGroupInfo{Members: "17, 18,19"}}
+	assert.DeepEqual(t, group.MemberIDs(), []string{"17", "18", "19"})
+	group = AvmGroup{}
+	assert.DeepEqual(t, group.MemberIDs(), []string{})
+}
+
+func TestSwitchGroup(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	var received []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		received = append(received, fmt.Sprintf("%v %v %v", q.Get("switchcmd"), q.Get("ain"), q.Get("onoff")))
+		fmt.Fprint(w, "1\n")
+	}
+	for _, cmd := range []string{"setswitchon", "setswitchoff", "setswitchtoggle", "setsimpleonoff"} {
+		box.handleSwitch(cmd, record)
+	}
+
+	outlets := &AvmGroup{AvmDevice: AvmDevice{AIN: "65:1A:11-900", Switch: &AvmDeviceSwitch{}}}
+	assert.NilError(t, f.SwitchGroupOn(outlets))
+	assert.NilError(t, f.SwitchGroupOff(outlets))
+	assert.NilError(t, f.SwitchGroupToggle(outlets))
+
+	lights := &AvmGroup{AvmDevice: AvmDevice{AIN: "grp0D5E21-3F1E20DCC", SimpleOnOff: &AvmDeviceSimpleonoff{}}}
+	assert.NilError(t, f.SwitchGroupOn(lights))
+	assert.NilError(t, f.SwitchGroupToggle(lights))
+
+	assert.DeepEqual(t, received, []string{
+		"setswitchon 65:1A:11-900 ",
+		"setswitchoff 65:1A:11-900 ",
+		"setswitchtoggle 65:1A:11-900 ",
+		"setsimpleonoff grp0D5E21-3F1E20DCC 1",
+		"setsimpleonoff grp0D5E21-3F1E20DCC 2",
+	})
+}
@@ -0,0 +1,151 @@
+package freepslib
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// HkrTemperature is a thermostat temperature as encoded by the AHA interface:
+// 16 to 56 in steps of 0.5°C, 253 for off and 254 for on
+type HkrTemperature int
+
+const (
+	HkrOff HkrTemperature = 253
+	HkrOn  HkrTemperature = 254
+
+	HkrMinCelsius = 8.0
+	HkrMaxCelsius = 28.0
+)
+
+// HkrMode distinguishes a thermostat that is permanently off or on from one that regulates a temperature
+type HkrMode int
+
+const (
+	HkrModeTemperature HkrMode = iota
+	HkrModeOff
+	HkrModeOn
+)
+
+// errorcode values of a thermostat
+const (
+	HkrErrorNone             = 0
+	HkrErrorNoAdaptation     = 1 // thermostat not mounted correctly
+	HkrErrorValveStroke      = 2 // valve stroke too short or battery too weak
+	HkrErrorNoValveMovement  = 3
+	HkrErrorPreparing        = 4 // installation is being prepared
+	HkrErrorInstallationMode = 5 // ready to be mounted
+	HkrErrorAdapting         = 6 // adapting to the valve stroke
+)
+
+// NewHkrTemperature converts degrees Celsius to the AHA encoding, rounded to 0.5°C
+func NewHkrTemperature(celsius float64) (HkrTemperature, error) {
+	if math.IsNaN(celsius) || celsius < HkrMinCelsius || celsius > HkrMaxCelsius {
+		return 0, fmt.Errorf("%w: %v°C is not between %v°C and %v°C", ErrOutOfRange, celsius, HkrMinCelsius, HkrMaxCelsius)
+	}
+	return HkrTemperature(math.Round(celsius * 2)), nil
+}
+
+func (t HkrTemperature) Mode() HkrMode {
+	switch t {
+	case HkrOff:
+		return HkrModeOff
+	case HkrOn:
+		return HkrModeOn
+	}
+	return HkrModeTemperature
+}
+
+// Celsius returns the temperature in degrees Celsius, it is only meaningful in HkrModeTemperature
+func (t HkrTemperature) Celsius() float64 {
+	return float64(t) / 2
+}
+
+// Valid returns true if the value can be sent to a thermostat
+func (t HkrTemperature) Valid() bool {
+	return t == HkrOff || t == HkrOn || (t.Celsius() >= HkrMinCelsius && t.Celsius() <= HkrMaxCelsius)
+}
+
+func (t HkrTemperature) String() string {
+	switch t.Mode() {
+	case HkrModeOff:
+		return "off"
+	case HkrModeOn:
+		return "on"
+	}
+	return fmt.Sprintf("%v°C", t.Celsius())
+}
+
+// CurrentTemperature returns the temperature measured by the thermostat in degrees Celsius
+func (h *AvmDeviceHkr) CurrentTemperature() float64 {
+	return HkrTemperature(h.Tist).Celsius()
+}
+
+func (h *AvmDeviceHkr) TargetTemperature() HkrTemperature {
+	return HkrTemperature(h.Tsoll)
+}
+
+func (h *AvmDeviceHkr) ComfortTemperature() HkrTemperature {
+	return HkrTemperature(h.Komfort)
+}
+
+func (h *AvmDeviceHkr) EcoTemperature() HkrTemperature {
+	return HkrTemperature(h.Absenk)
+}
+
+// Degrees returns the temperature in degrees Celsius, the offset is already applied
+func (t *AvmDeviceTemperature) Degrees() float64 {
+	return float64(t.Celsius) / 10
+}
+
+// OffsetDegrees returns the offset configured for the sensor in degrees Celsius
+func (t *AvmDeviceTemperature) OffsetDegrees() float64 {
+	return float64(t.Offset) / 10
+}
+
+func (f *Freeps) queryHkrTemperature(ctx context.Context, switchcmd string, ain string) (HkrTemperature, error) {
+	byt, err := f.queryHomeAutomation(ctx, switchcmd, ain, map[string]string{})
+	if err != nil {
+		return 0, err
+	}
+	v, err := parseIntValue(ain, byt)
+	return HkrTemperature(v), f.checkPresent(ctx, ain, err)
+}
+
+// SetTargetTemperature sets the temperature the thermostat regulates to, or turns it off or on permanently
+func (f *Freeps) SetTargetTemperature(ain string, temp HkrTemperature) error {
+	return f.SetTargetTemperatureContext(context.Background(), ain, temp)
+}
+
+func (f *Freeps) SetTargetTemperatureContext(ctx context.Context, ain string, temp HkrTemperature) error {
+	if !temp.Valid() {
+		return fmt.Errorf("%w: %v is not a valid thermostat temperature", ErrOutOfRange, int(temp))
+	}
+	_, err := f.queryHomeAutomation(ctx, "sethkrtsoll", ain, map[string]string{"param": fmt.Sprint(int(temp))})
+	return err
+}
+
+func (f *Freeps) GetTargetTemperature(ain string) (HkrTemperature, error) {
+	return f.GetTargetTemperatureContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetTargetTemperatureContext(ctx context.Context, ain string) (HkrTemperature, error) {
+	return f.queryHkrTemperature(ctx, "gethkrtsoll", ain)
+}
+
+func (f *Freeps) GetComfortTemperature(ain string) (HkrTemperature, error) {
+	return f.GetComfortTemperatureContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetComfortTemperatureContext(ctx context.Context, ain string) (HkrTemperature, error) {
+	return f.queryHkrTemperature(ctx, "gethkrkomfort", ain)
+}
+
+// GetEcoTemperature returns the temperature the thermostat lowers to, called Absenktemperatur by AVM
+func (f *Freeps) GetEcoTemperature(ain string) (HkrTemperature, error) {
+	return f.GetEcoTemperatureContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetEcoTemperatureContext(ctx context.Context, ain string) (HkrTemperature, error) {
+	return f.queryHkrTemperature(ctx, "gethkrabsenk", ain)
+}
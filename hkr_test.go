@@ -0,0 +1,114 @@
+package freepslib
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestHkrTemperature(t *testing.T) {
+	temp, err := NewHkrTemperature(21.5)
+	assert.NilError(t, err)
+	assert.Equal(t, temp, HkrTemperature(43))
+	assert.Equal(t, temp.Mode(), HkrModeTemperature)
+	assert.Equal(t, temp.Celsius(), 21.5)
+	assert.Equal(t, temp.String(), "21.5°C")
+
+	temp, err = NewHkrTemperature(20.7)
+	assert.NilError(t, err)
+	assert.Equal(t, temp.Celsius(), 20.5)
+
+	temp, err = NewHkrTemperature(8)
+	assert.NilError(t, err)
+	assert.Equal(t, temp, HkrTemperature(16))
+	temp, err = NewHkrTemperature(28)
+	assert.NilError(t, err)
+	assert.Equal(t, temp, HkrTemperature(56))
+
+	for _, celsius := range []float64{7.9, 28.1, -5, math.NaN()} {
+		_, err = NewHkrTemperature(celsius)
+		assert.Assert(t, errors.Is(err, ErrOutOfRange), celsius)
+	}
+
+	assert.Equal(t, HkrOff.Mode(), HkrModeOff)
+	assert.Equal(t, HkrOn.Mode(), HkrModeOn)
+	assert.Equal(t, HkrOff.String(), "off")
+	assert.Assert(t, HkrOff.Valid())
+	assert.Assert(t, HkrOn.Valid())
+	assert.Assert(t, !HkrTemperature(15).Valid())
+	assert.Assert(t, !HkrTemperature(57).Valid())
+	assert.Assert(t, !HkrTemperature(255).Valid())
+}
+
+func TestHkrFromDeviceList(t *testing.T) {
+	byteValue, err := os.ReadFile("./_testdata/large_devicelist.xml")
+	assert.NilError(t, err)
+	dl, err := parseDeviceList(byteValue)
+	assert.NilError(t, err)
+
+	dev := dl.Device[0]
+	assert.Equal(t, dev.HKR.CurrentTemperature(), 19.0)
+	assert.Equal(t, dev.HKR.TargetTemperature().Celsius(), 16.0)
+	assert.Equal(t, dev.HKR.ComfortTemperature().Celsius(), 18.0)
+	assert.Equal(t, dev.HKR.EcoTemperature().Celsius(), 16.0)
+	assert.Equal(t, dev.Temperature.Degrees(), 19.0)
+	assert.Equal(t, dev.Temperature.OffsetDegrees(), 0.0)
+}
+
+func TestHkrAPI(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	tsoll := "40"
+	box.handleSwitch("sethkrtsoll", func(w http.ResponseWriter, r *http.Request) {
+		tsoll = r.URL.Query().Get("param")
+		w.Write([]byte(tsoll + "\n"))
+	})
+	box.handleSwitch("gethkrtsoll", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tsoll + "\n"))
+	})
+	box.respond("gethkrkomfort", "42")
+	box.respond("gethkrabsenk", "34")
+
+	temp, err := NewHkrTemperature(22)
+	assert.NilError(t, err)
+	assert.NilError(t, f.SetTargetTemperature("11795 ANON01", temp))
+	assert.Equal(t, tsoll, "44")
+	got, err := f.GetTargetTemperature("11795 ANON01")
+	assert.NilError(t, err)
+	assert.Equal(t, got.Celsius(), 22.0)
+
+	assert.NilError(t, f.SetTargetTemperature("11795 ANON01", HkrOff))
+	assert.Equal(t, tsoll, "253")
+	got, err = f.GetTargetTemperature("11795 ANON01")
+	assert.NilError(t, err)
+	assert.Equal(t, got.Mode(), HkrModeOff)
+
+	err = f.SetTargetTemperature("11795 ANON01", HkrTemperature(60))
+	assert.Assert(t, errors.Is(err, ErrOutOfRange))
+	assert.Equal(t, tsoll, "253")
+
+	comfort, err := f.GetComfortTemperature("11795 ANON01")
+	assert.NilError(t, err)
+	assert.Equal(t, comfort.Celsius(), 21.0)
+	eco, err := f.GetEcoTemperature("11795 ANON01")
+	assert.NilError(t, err)
+	assert.Equal(t, eco.Celsius(), 17.0)
+}
+
+func TestHkrNotPresent(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("gethkrtsoll", "inval")
+	presentOnly(box, "11795 ANON01")
+
+	_, err := f.GetTargetTemperature("11795 ANON02")
+	assert.Assert(t, errors.Is(err, ErrDeviceNotPresent), err)
+	_, err = f.GetTargetTemperature("11795 ANON01")
+	assert.Assert(t, errors.Is(err, ErrInvalidValue), err)
+	assert.Assert(t, !errors.Is(err, ErrDeviceNotPresent))
+}
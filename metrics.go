@@ -1,6 +1,7 @@
 package freepslib
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,44 +21,47 @@ type FritzBoxMetrics struct {
 	TransmissionRateDown int64 `json:"ByteSendRate"`
 }
 
-func (f *Freeps) initMetrics() error {
+// initMetrics loads the UPnP services once and returns them
+func (f *Freeps) initMetrics(ctx context.Context) (*fritzbox_upnp.Root, error) {
+	f.metricsMu.Lock()
+	defer f.metricsMu.Unlock()
 	if f.metricsObject != nil {
-		return nil
+		return f.metricsObject, nil
 	}
 
-	var err error
-	f.metricsObject, err = fritzbox_upnp.LoadServices("http://"+f.conf.Address+":49000", f.conf.User, f.conf.Password, false)
+	root, err := fritzbox_upnp.LoadServicesWithClient(ctx, "http://"+f.conf.Address+":49000", f.conf.User, f.conf.Password, f.getHttpClient())
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	f.metricsObject = root
+	return root, nil
 }
 
 // helper function to deal with short service names
-func (f *Freeps) getService(svcName string) (*fritzbox_upnp.Service, error) {
-	err := f.initMetrics()
+func (f *Freeps) getService(ctx context.Context, svcName string) (*fritzbox_upnp.Service, error) {
+	metricsObject, err := f.initMetrics(ctx)
 	if err != nil {
 		return nil, err
 	}
-	svc, ok := f.metricsObject.Services[svcName]
+	svc, ok := metricsObject.Services[svcName]
 	if ok {
 		return svc, nil
 	}
 
-	for k, v := range f.metricsObject.Services {
+	for k, v := range metricsObject.Services {
 		if svcName == f.getShortServiceName(k) {
 			return v, nil
 		}
 	}
 
 	if f.conf.Verbose {
-		log.Printf("Available services:\n %v\n", f.metricsObject.Services)
+		log.Printf("Available services:\n %v\n", metricsObject.Services)
 	}
 	return nil, errors.New("cannot find service " + svcName)
 }
 
-func (f *Freeps) getAction(serviceName string, actionName string) (*fritzbox_upnp.Action, error) {
-	service, err := f.getService(serviceName)
+func (f *Freeps) getAction(ctx context.Context, serviceName string, actionName string) (*fritzbox_upnp.Action, error) {
+	service, err := f.getService(ctx, serviceName)
 	if err != nil {
 		return nil, err
 	}
@@ -71,14 +75,14 @@ func (f *Freeps) getAction(serviceName string, actionName string) (*fritzbox_upn
 	return action, nil
 }
 
-func (f *Freeps) getMetricsMap(serviceName string, actionName string, arg *fritzbox_upnp.ActionArgument) (fritzbox_upnp.Result, error) {
+func (f *Freeps) getMetricsMap(ctx context.Context, serviceName string, actionName string, arg *fritzbox_upnp.ActionArgument) (fritzbox_upnp.Result, error) {
 	rmap := fritzbox_upnp.Result{}
 
-	action, err := f.getAction(serviceName, actionName)
+	action, err := f.getAction(ctx, serviceName, actionName)
 	if err != nil {
 		return rmap, err
 	}
-	rmap, err = action.Call(arg)
+	rmap, err = action.CallContext(ctx, arg)
 	if err != nil {
 		return rmap, fmt.Errorf("cannot call action %v: %w", actionName, err)
 	}
@@ -86,17 +90,23 @@ func (f *Freeps) getMetricsMap(serviceName string, actionName string, arg *fritz
 }
 
 func (f *Freeps) GetMetrics() (FritzBoxMetrics, error) {
+	return f.GetMetricsContext(context.Background())
+}
+
+func (f *Freeps) GetMetricsContext(ctx context.Context) (FritzBoxMetrics, error) {
 	var r FritzBoxMetrics
-	m, err := f.getMetricsMap("urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1", "GetAddonInfos", nil)
+	m, err := f.getMetricsMap(ctx, "urn:schemas-upnp-org:service:WANCommonInterfaceConfig:1", "GetAddonInfos", nil)
+	f.metricsMu.Lock()
 	if f.metricsObject != nil {
 		r.DeviceModelName = f.metricsObject.Device.ModelName
 		r.DeviceFriendlyName = f.metricsObject.Device.FriendlyName
 	}
+	f.metricsMu.Unlock()
 	if err != nil {
 		return r, err
 	}
 
-	m2, err := f.getMetricsMap("urn:schemas-upnp-org:service:WANIPConnection:1", "GetStatusInfo", nil)
+	m2, err := f.getMetricsMap(ctx, "urn:schemas-upnp-org:service:WANIPConnection:1", "GetStatusInfo", nil)
 	if err != nil {
 		return r, err
 	}
@@ -117,20 +127,32 @@ func (f *Freeps) GetMetrics() (FritzBoxMetrics, error) {
 }
 
 func (f *Freeps) GetUpnpDataMap(serviceName string, actionName string) (map[string]interface{}, error) {
-	return f.getMetricsMap(serviceName, actionName, nil)
+	return f.GetUpnpDataMapContext(context.Background(), serviceName, actionName)
+}
+
+func (f *Freeps) GetUpnpDataMapContext(ctx context.Context, serviceName string, actionName string) (map[string]interface{}, error) {
+	return f.getMetricsMap(ctx, serviceName, actionName, nil)
 }
 
 func (f *Freeps) CallUpnpActionWithArgument(serviceName string, actionName string, argName string, argValue interface{}) (map[string]interface{}, error) {
-	return f.getMetricsMap(serviceName, actionName, &fritzbox_upnp.ActionArgument{Name: argName, Value: argValue})
+	return f.CallUpnpActionWithArgumentContext(context.Background(), serviceName, actionName, argName, argValue)
+}
+
+func (f *Freeps) CallUpnpActionWithArgumentContext(ctx context.Context, serviceName string, actionName string, argName string, argValue interface{}) (map[string]interface{}, error) {
+	return f.getMetricsMap(ctx, serviceName, actionName, &fritzbox_upnp.ActionArgument{Name: argName, Value: argValue})
 }
 
 func (f *Freeps) GetUpnpServices() ([]string, error) {
-	err := f.initMetrics()
+	return f.GetUpnpServicesContext(context.Background())
+}
+
+func (f *Freeps) GetUpnpServicesContext(ctx context.Context) ([]string, error) {
+	metricsObject, err := f.initMetrics(ctx)
 	if err != nil {
 		return []string{}, err
 	}
-	keys := make([]string, 0, len(f.metricsObject.Services))
-	for k := range f.metricsObject.Services {
+	keys := make([]string, 0, len(metricsObject.Services))
+	for k := range metricsObject.Services {
 		keys = append(keys, k)
 	}
 
@@ -138,12 +160,16 @@ func (f *Freeps) GetUpnpServices() ([]string, error) {
 }
 
 func (f *Freeps) GetUpnpServicesShort() ([]string, error) {
-	err := f.initMetrics()
+	return f.GetUpnpServicesShortContext(context.Background())
+}
+
+func (f *Freeps) GetUpnpServicesShortContext(ctx context.Context) ([]string, error) {
+	metricsObject, err := f.initMetrics(ctx)
 	if err != nil {
 		return []string{}, err
 	}
-	keys := make([]string, 0, len(f.metricsObject.Services))
-	for k := range f.metricsObject.Services {
+	keys := make([]string, 0, len(metricsObject.Services))
+	for k := range metricsObject.Services {
 		keys = append(keys, f.getShortServiceName(k))
 	}
 
@@ -151,7 +177,11 @@ func (f *Freeps) GetUpnpServicesShort() ([]string, error) {
 }
 
 func (f *Freeps) GetUpnpServiceActions(serviceName string) ([]string, error) {
-	service, err := f.getService(serviceName)
+	return f.GetUpnpServiceActionsContext(context.Background(), serviceName)
+}
+
+func (f *Freeps) GetUpnpServiceActionsContext(ctx context.Context, serviceName string) ([]string, error) {
+	service, err := f.getService(ctx, serviceName)
 	if err != nil {
 		return []string{}, err
 	}
@@ -165,7 +195,11 @@ func (f *Freeps) GetUpnpServiceActions(serviceName string) ([]string, error) {
 }
 
 func (f *Freeps) GetUpnpServiceActionArguments(serviceName string, actionName string) ([]string, error) {
-	action, err := f.getAction(serviceName, actionName)
+	return f.GetUpnpServiceActionArgumentsContext(context.Background(), serviceName, actionName)
+}
+
+func (f *Freeps) GetUpnpServiceActionArgumentsContext(ctx context.Context, serviceName string, actionName string) ([]string, error) {
+	action, err := f.getAction(ctx, serviceName, actionName)
 	if err != nil {
 		return []string{}, err
 	}
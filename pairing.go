@@ -0,0 +1,107 @@
+package freepslib
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// AvmSubscriptionCode is the state of a DECT ULE registration as returned by getsubscriptionstate
+type AvmSubscriptionCode int
+
+const (
+	SubscriptionIdle    AvmSubscriptionCode = 0
+	SubscriptionRunning AvmSubscriptionCode = 1
+	SubscriptionTimeout AvmSubscriptionCode = 2
+	SubscriptionError   AvmSubscriptionCode = 3
+)
+
+type AvmSubscriptionState struct {
+	Code      AvmSubscriptionCode `xml:"code,attr"`
+	LatestAIN string              `xml:"latestain"`
+}
+
+// pairingPollInterval is the time between two getsubscriptionstate requests
+var pairingPollInterval = time.Second
+
+// pairingStartGrace is how long the box may keep reporting idle after startulesubscription before the
+// registration is considered to have ended without ever running
+var pairingStartGrace = 5 * time.Second
+
+func parseSubscriptionState(byt []byte) (*AvmSubscriptionState, error) {
+	var state AvmSubscriptionState
+	err := xml.Unmarshal(byt, &state)
+	if err != nil {
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+	return &state, nil
+}
+
+func (f *Freeps) GetSubscriptionState() (*AvmSubscriptionState, error) {
+	return f.GetSubscriptionStateContext(context.Background())
+}
+
+func (f *Freeps) GetSubscriptionStateContext(ctx context.Context) (*AvmSubscriptionState, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getsubscriptionstate", "", map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	return parseSubscriptionState(byt)
+}
+
+// PairNewDevice starts the DECT ULE registration, waits until a new device is registered and returns it.
+// Idle is only taken as the end of the registration after it was reported as running (or pairingStartGrace
+// passed). If the latest AIN did not change by then, the registration was aborted or no device registered.
+// It returns ErrPairingTimeout if the FRITZ!Box stopped waiting without a new device and ErrPairingFailed
+// if the registration was aborted; cancel ctx to stop waiting earlier.
+func (f *Freeps) PairNewDevice(ctx context.Context) (*AvmDevice, error) {
+	before, err := f.GetSubscriptionStateContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.queryHomeAutomation(ctx, "startulesubscription", "", map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	running := false
+	ticker := time.NewTicker(pairingPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		state, err := f.GetSubscriptionStateContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		switch state.Code {
+		case SubscriptionRunning:
+			running = true
+		case SubscriptionTimeout:
+			return nil, ErrPairingTimeout
+		case SubscriptionIdle:
+			if !running {
+				// the box has not picked up the start yet, unless a new device got registered right away
+				if state.LatestAIN != "" && state.LatestAIN != before.LatestAIN {
+					return f.GetDeviceContext(ctx, state.LatestAIN)
+				}
+				if time.Since(started) < pairingStartGrace {
+					continue
+				}
+				return nil, ErrPairingTimeout
+			}
+			if state.LatestAIN == "" || state.LatestAIN == before.LatestAIN {
+				return nil, ErrPairingTimeout
+			}
+			return f.GetDeviceContext(ctx, state.LatestAIN)
+		default:
+			return nil, fmt.Errorf("%w: subscription state %v", ErrPairingFailed, state.Code)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package freepslib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeSubscription reports the given states one after another once startulesubscription was called
+type fakeSubscription struct {
+	mu      sync.Mutex
+	started bool
+	states  []string
+}
+
+func (s *fakeSubscription) register(box *fakeBox) {
+	box.handleSwitch("startulesubscription", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.started = true
+	})
+	box.handleSwitch("getsubscriptionstate", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		state := s.states[0]
+		if s.started && len(s.states) > 1 {
+			s.states = s.states[1:]
+			state = s.states[0]
+		}
+		fmt.Fprint(w, state+"\n")
+	})
+}
+
+func setPairingPollInterval(t *testing.T, d time.Duration) {
+	old := pairingPollInterval
+	pairingPollInterval = d
+	t.Cleanup(func() { pairingPollInterval = old })
+}
+
+func setPairingStartGrace(t *testing.T, d time.Duration) {
+	old := pairingStartGrace
+	pairingStartGrace = d
+	t.Cleanup(func() { pairingStartGrace = old })
+}
+
+func TestPairNewDevice(t *testing.T) {
+	setPairingPollInterval(t, time.Millisecond)
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("getdeviceinfos", `<device identifier="09995 0335100" id="16" productname="FRITZ!DECT 301"><present>1</present><name>Thermostat</name></device>`)
+
+	sub := &fakeSubscription{states: []string{
+		`<state code="0"><latestain>11959 0171328</latestain></state>`,
+		`<state code="1"><latestain>11959 0171328</latestain></state>`,
+		`<state code="1"><latestain>11959 0171328</latestain></state>`,
+		`<state code="0"><latestain>09995 0335100</latestain></state>`,
+	}}
+	sub.register(box)
+
+	dev, err := f.PairNewDevice(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, dev.AIN, "09995 0335100")
+	assert.Equal(t, dev.Name, "Thermostat")
+}
+
+func TestPairNewDeviceIdleBeforeRunning(t *testing.T) {
+	setPairingPollInterval(t, time.Millisecond)
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("getdeviceinfos", `<device identifier="09995 0335100" id="16"><present>1</present><name>Thermostat</name></device>`)
+
+	// the box still reports idle right after the start
+	sub := &fakeSubscription{states: []string{
+		`<state code="0"><latestain>11959 0171328</latestain></state>`,
+		`<state code="0"><latestain>11959 0171328</latestain></state>`,
+		`<state code="0"><latestain>11959 0171328</latestain></state>`,
+		`<state code="1"><latestain>11959 0171328</latestain></state>`,
+		`<state code="0"><latestain>09995 0335100</latestain></state>`,
+	}}
+	sub.register(box)
+	dev, err := f.PairNewDevice(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, dev.AIN, "09995 0335100")
+
+	// the registration ended without a new device, the previously paired one must not be returned
+	sub = &fakeSubscription{states: []string{
+		`<state code="0"><latestain>11959 0171328</latestain></state>`,
+		`<state code="0"><latestain>11959 0171328</latestain></state>`,
+		`<state code="1"><latestain>11959 0171328</latestain></state>`,
+		`<state code="0"><latestain>11959 0171328</latestain></state>`,
+	}}
+	sub.register(box)
+	_, err = f.PairNewDevice(context.Background())
+	assert.Assert(t, errors.Is(err, ErrPairingTimeout), err)
+}
+
+func TestPairNewDeviceFailures(t *testing.T) {
+	setPairingPollInterval(t, time.Millisecond)
+	setPairingStartGrace(t, 10*time.Millisecond)
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	(&fakeSubscription{states: []string{`<state code="0"><latestain></latestain></state>`, `<state code="1"><latestain></latestain></state>`, `<state code="2"><latestain></latestain></state>`}}).register(box)
+	_, err := f.PairNewDevice(context.Background())
+	assert.Assert(t, errors.Is(err, ErrPairingTimeout), err)
+
+	(&fakeSubscription{states: []string{`<state code="0"><latestain>1</latestain></state>`, `<state code="0"><latestain>1</latestain></state>`}}).register(box)
+	_, err = f.PairNewDevice(context.Background())
+	assert.Assert(t, errors.Is(err, ErrPairingTimeout), err)
+
+	(&fakeSubscription{states: []string{`<state code="0"><latestain></latestain></state>`, `<state code="3"><latestain></latestain></state>`}}).register(box)
+	_, err = f.PairNewDevice(context.Background())
+	assert.Assert(t, errors.Is(err, ErrPairingFailed), err)
+
+	(&fakeSubscription{states: []string{`<state code="1"><latestain></latestain></state>`}}).register(box)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = f.PairNewDevice(ctx)
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded), err)
+}
@@ -0,0 +1,291 @@
+package freepslib
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"time"
+)
+
+// DefaultKeepAliveInterval refreshes the session well before the FRITZ!Box drops it after 20 minutes of inactivity
+const DefaultKeepAliveInterval = 15 * time.Minute
+
+// Login authenticates against the FRITZ!Box and replaces the current session, the previous
+// session is closed on the FRITZ!Box. If a login is already in flight, Login waits for it instead.
+func (f *Freeps) Login() error {
+	return f.LoginContext(context.Background())
+}
+
+func (f *Freeps) LoginContext(ctx context.Context) error {
+	return f.relogin(ctx, f.SID())
+}
+
+// login authenticates and closes the previous session, it must only be called by relogin
+func (f *Freeps) login(ctx context.Context) error {
+	f.logger.Debugf("Trying to log into fritzbox")
+
+	session, scheme, err := f.getSid(ctx)
+	if err != nil {
+		f.logger.Errorf("Failed to authenticate")
+		return err
+	}
+
+	f.sessionMu.Lock()
+	oldSID := f.sid
+	f.sid = session.SID
+	f.session = session
+	f.authScheme = scheme
+	f.sessionMu.Unlock()
+
+	if oldSID != "" && oldSID != session.SID {
+		if err := f.closeSession(ctx, oldSID); err != nil {
+			f.logger.Warnf("Failed to close previous session: %v", err)
+		}
+	}
+	return nil
+}
+
+// Logout stops the keepalive and closes the current session on the FRITZ!Box, it's a no-op if there is no session
+func (f *Freeps) Logout() error {
+	return f.LogoutContext(context.Background())
+}
+
+func (f *Freeps) LogoutContext(ctx context.Context) error {
+	f.StopKeepAlive()
+	// a login started before might still store its session
+	if err := f.waitForLogin(ctx); err != nil {
+		return err
+	}
+	sid := f.SID()
+	if sid == "" {
+		return nil
+	}
+	return f.closeSession(ctx, sid)
+}
+
+// closeSession logs out the given session on the FRITZ!Box
+func (f *Freeps) closeSession(ctx context.Context, sid string) error {
+	params := url.Values{}
+	params.Set("version", "2")
+	params.Set("logout", "1")
+	params.Set("sid", sid)
+	_, err := f.querySessionInfo(ctx, params)
+	if err != nil {
+		return err
+	}
+
+	f.clearSession(sid)
+	return nil
+}
+
+// ValidateSession checks whether the current session is still accepted by the FRITZ!Box
+// without re-authenticating. As a side effect the idle timeout of a valid session is reset.
+func (f *Freeps) ValidateSession() (bool, error) {
+	return f.ValidateSessionContext(context.Background())
+}
+
+func (f *Freeps) ValidateSessionContext(ctx context.Context) (bool, error) {
+	sid := f.SID()
+	if sid == "" {
+		return false, nil
+	}
+
+	params := url.Values{}
+	params.Set("version", "2")
+	params.Set("sid", sid)
+	session, err := f.querySessionInfo(ctx, params)
+	if err != nil {
+		return false, err
+	}
+	if session.SID != sid {
+		f.clearSession(sid)
+		return false, nil
+	}
+
+	f.sessionMu.Lock()
+	defer f.sessionMu.Unlock()
+	if f.sid == sid {
+		f.session = session
+	}
+	return true, nil
+}
+
+// SID returns the ID of the current session or an empty string if not logged in
+func (f *Freeps) SID() string {
+	f.sessionMu.RLock()
+	defer f.sessionMu.RUnlock()
+	return f.sid
+}
+
+// Session returns the session info of the most recent successful login or nil
+func (f *Freeps) Session() *AvmSessionInfo {
+	f.sessionMu.RLock()
+	defer f.sessionMu.RUnlock()
+	return f.session
+}
+
+// HasRight returns true if the logged-in user was granted the given right, it
+// logs in if there is no session yet
+func (f *Freeps) HasRight(right AvmRight) (bool, error) {
+	return f.HasRightContext(context.Background(), right)
+}
+
+func (f *Freeps) HasRightContext(ctx context.Context, right AvmRight) (bool, error) {
+	session := f.Session()
+	if session == nil {
+		if err := f.LoginContext(ctx); err != nil {
+			return false, err
+		}
+		session = f.Session()
+		if session == nil {
+			return false, ErrNoSession
+		}
+	}
+	return session.HasRight(right), nil
+}
+
+// StartKeepAlive validates the session every interval to prevent it from timing out
+// and logs in again if the FRITZ!Box dropped it anyway. A running keepalive is restarted.
+func (f *Freeps) StartKeepAlive(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultKeepAliveInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	f.sessionMu.Lock()
+	if f.keepAliveCancel != nil {
+		f.keepAliveCancel()
+	}
+	f.keepAliveCancel = cancel
+	f.sessionMu.Unlock()
+
+	go f.keepAlive(ctx, interval)
+}
+
+// StopKeepAlive stops the background keepalive started by StartKeepAlive
+func (f *Freeps) StopKeepAlive() {
+	f.sessionMu.Lock()
+	defer f.sessionMu.Unlock()
+	if f.keepAliveCancel != nil {
+		f.keepAliveCancel()
+		f.keepAliveCancel = nil
+	}
+}
+
+func (f *Freeps) keepAlive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		sid := f.SID()
+		valid, err := f.ValidateSessionContext(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			f.logger.Warnf("Keepalive failed to validate session: %v", err)
+			continue
+		}
+		if valid {
+			continue
+		}
+		f.logger.Debugf("Session expired, logging in again")
+		if err := f.relogin(ctx, sid); err != nil {
+			f.logger.Warnf("Keepalive failed to log in: %v", err)
+		}
+	}
+}
+
+// loginCall is a login in flight that concurrent requests can wait for
+type loginCall struct {
+	done chan struct{}
+	err  error
+}
+
+// relogin is called by Login, the keepalive and requests that were rejected while using staleSID.
+// Only one login is in flight at a time, all other callers wait for it and share its result.
+func (f *Freeps) relogin(ctx context.Context, staleSID string) error {
+	for {
+		f.sessionMu.Lock()
+		if f.sid != "" && f.sid != staleSID {
+			// somebody else already logged in since the request was sent
+			f.sessionMu.Unlock()
+			return nil
+		}
+		call := f.pendingLogin
+		if call == nil {
+			call = &loginCall{done: make(chan struct{})}
+			f.pendingLogin = call
+			f.sessionMu.Unlock()
+
+			call.err = f.login(ctx)
+
+			f.sessionMu.Lock()
+			f.pendingLogin = nil
+			f.sessionMu.Unlock()
+			close(call.done)
+			return call.err
+		}
+		f.sessionMu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-call.done:
+		}
+		if call.err == nil {
+			return nil
+		}
+		if ctx.Err() == nil && (errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+			// the caller that started the login gave up, but we are still interested
+			continue
+		}
+		return call.err
+	}
+}
+
+// waitForLogin returns once no login is in flight
+func (f *Freeps) waitForLogin(ctx context.Context) error {
+	f.sessionMu.RLock()
+	call := f.pendingLogin
+	f.sessionMu.RUnlock()
+	if call == nil {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.done:
+		return nil
+	}
+}
+
+func (f *Freeps) clearSession(sid string) {
+	f.sessionMu.Lock()
+	defer f.sessionMu.Unlock()
+	if f.sid == sid {
+		f.sid = ""
+		f.session = nil
+	}
+}
+
+func (f *Freeps) querySessionInfo(ctx context.Context, params url.Values) (*AvmSessionInfo, error) {
+	login_url := f.baseURL() + "/login_sid.lua"
+	resp, err := f.httpGet(ctx, login_url+"?"+params.Encode())
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	byt, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &RequestError{Err: err}
+	}
+	return parseSessionInfo(byt)
+}
@@ -0,0 +1,123 @@
+package freepslib
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestLoginLogout(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	assert.Equal(t, f.SID(), "")
+	valid, err := f.ValidateSession()
+	assert.NilError(t, err)
+	assert.Assert(t, !valid)
+
+	assert.NilError(t, f.Login())
+	assert.Assert(t, f.SID() != "")
+	assert.Equal(t, f.AuthScheme(), AuthSchemePBKDF2)
+	hasRight, err := f.HasRight(RightHomeAuto)
+	assert.NilError(t, err)
+	assert.Assert(t, hasRight)
+
+	valid, err = f.ValidateSession()
+	assert.NilError(t, err)
+	assert.Assert(t, valid)
+
+	assert.NilError(t, f.Logout())
+	assert.Equal(t, f.SID(), "")
+	assert.Assert(t, f.Session() == nil)
+	assert.Equal(t, box.sessionCount(), 0)
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "wrong")
+
+	err := f.Login()
+	assert.ErrorContains(t, err, "wrong user/password")
+	assert.Equal(t, f.SID(), "")
+}
+
+func TestValidateExpiredSession(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	assert.NilError(t, f.Login())
+	box.expireSessions()
+
+	valid, err := f.ValidateSession()
+	assert.NilError(t, err)
+	assert.Assert(t, !valid)
+	assert.Equal(t, f.SID(), "")
+}
+
+func TestKeepAlive(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	assert.NilError(t, f.Login())
+	staleSID := f.SID()
+	box.expireSessions()
+
+	f.StartKeepAlive(10 * time.Millisecond)
+	for i := 0; i < 100 && (f.SID() == "" || f.SID() == staleSID); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	f.StopKeepAlive()
+	assert.Assert(t, box.loginCount() >= 2)
+
+	valid, err := f.ValidateSession()
+	assert.NilError(t, err)
+	assert.Assert(t, valid)
+}
+
+func TestContextCancelsLoginRetry(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	box.handleSwitch("getswitchlist", func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		w.WriteHeader(http.StatusForbidden)
+	})
+	assert.NilError(t, f.Login())
+
+	_, err := f.HomeAutomationContext(ctx, "getswitchlist", "", nil)
+	assert.Assert(t, errors.Is(err, context.Canceled), err)
+	assert.Equal(t, box.loginCount(), 1)
+}
+
+func TestLogoutStopsKeepAlive(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	assert.NilError(t, f.Login())
+	f.StartKeepAlive(5 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.NilError(t, f.Logout())
+
+	// the keepalive must not log in again after the session was closed on purpose
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, f.SID(), "")
+	assert.Equal(t, box.sessionCount(), 0)
+	assert.Equal(t, box.loginCount(), 1)
+}
+
+func TestLoginClosesPreviousSession(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	assert.NilError(t, f.Login())
+	first := f.SID()
+	assert.NilError(t, f.Login())
+	assert.Assert(t, f.SID() != first)
+	assert.Equal(t, box.loginCount(), 2)
+	assert.Equal(t, box.sessionCount(), 1)
+}
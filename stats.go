@@ -0,0 +1,120 @@
+package freepslib
+
+import (
+	"context"
+	"encoding/xml"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AvmStatsSample struct {
+	Time  time.Time
+	Value float64
+}
+
+// AvmStatsSeries is one history returned by getbasicdevicestats, samples are ordered oldest first
+type AvmStatsSeries struct {
+	Grid    time.Duration
+	Samples []AvmStatsSample
+}
+
+// AvmDeviceStats contains the history of a device in °C, V, W, Wh and % relative humidity
+type AvmDeviceStats struct {
+	Temperature []AvmStatsSeries `json:",omitempty"`
+	Voltage     []AvmStatsSeries `json:",omitempty"`
+	Power       []AvmStatsSeries `json:",omitempty"`
+	Energy      []AvmStatsSeries `json:",omitempty"`
+	Humidity    []AvmStatsSeries `json:",omitempty"`
+}
+
+type avmStats struct {
+	Count    int    `xml:"count,attr"`
+	Grid     int    `xml:"grid,attr"`
+	DataTime int64  `xml:"datatime,attr"`
+	Values   string `xml:",chardata"`
+}
+
+type avmDeviceStats struct {
+	Temperature []avmStats `xml:"temperature>stats"`
+	Voltage     []avmStats `xml:"voltage>stats"`
+	Power       []avmStats `xml:"power>stats"`
+	Energy      []avmStats `xml:"energy>stats"`
+	Humidity    []avmStats `xml:"humidity>stats"`
+}
+
+// series converts the comma-separated values, the first one was measured at datatime (or now, if the box
+// does not send it), each following one a grid interval earlier; missing values ("-") are skipped
+func (s *avmStats) series(scale float64, now time.Time) (AvmStatsSeries, error) {
+	r := AvmStatsSeries{Grid: time.Duration(s.Grid) * time.Second}
+	end := now
+	if s.DataTime != 0 {
+		end = time.Unix(s.DataTime, 0)
+	}
+	values := strings.Split(strings.TrimSpace(s.Values), ",")
+	for i := len(values) - 1; i >= 0; i-- {
+		v := strings.TrimSpace(values[i])
+		if v == "" || v == "-" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return r, err
+		}
+		r.Samples = append(r.Samples, AvmStatsSample{Time: end.Add(-time.Duration(i) * r.Grid), Value: n * scale})
+	}
+	return r, nil
+}
+
+func convertStats(stats []avmStats, scale float64, now time.Time) ([]AvmStatsSeries, error) {
+	var r []AvmStatsSeries
+	for i := range stats {
+		s, err := stats[i].series(scale, now)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, s)
+	}
+	return r, nil
+}
+
+func parseDeviceStats(byt []byte, now time.Time) (*AvmDeviceStats, error) {
+	var raw avmDeviceStats
+	err := xml.Unmarshal(byt, &raw)
+	if err != nil {
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+
+	r := &AvmDeviceStats{}
+	for _, c := range []struct {
+		dst   *[]AvmStatsSeries
+		src   []avmStats
+		scale float64
+	}{
+		{&r.Temperature, raw.Temperature, 0.1},
+		{&r.Voltage, raw.Voltage, 0.001},
+		{&r.Power, raw.Power, 0.01},
+		{&r.Energy, raw.Energy, 1},
+		{&r.Humidity, raw.Humidity, 1},
+	} {
+		*c.dst, err = convertStats(c.src, c.scale, now)
+		if err != nil {
+			return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+		}
+	}
+	return r, nil
+}
+
+// GetDeviceStats returns the temperature, voltage, power, energy and humidity history of a device
+func (f *Freeps) GetDeviceStats(ain string) (*AvmDeviceStats, error) {
+	return f.GetDeviceStatsContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetDeviceStatsContext(ctx context.Context, ain string) (*AvmDeviceStats, error) {
+	now := time.Now()
+	byt, err := f.queryHomeAutomation(ctx, "getbasicdevicestats", ain, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	return parseDeviceStats(byt, now)
+}
@@ -0,0 +1,55 @@
+package freepslib
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+const deviceStatsXML = `<devicestats>
+<temperature><stats count="3" grid="900" datatime="1700000000">225,-,218</stats></temperature>
+<voltage><stats count="2" grid="10" datatime="1700000000">229174,230012</stats></voltage>
+<power><stats count="2" grid="10" datatime="1700000000">1250,0</stats></power>
+<energy><stats count="2" grid="2678400" datatime="1700000000">1530,1200</stats><stats count="2" grid="86400" datatime="1700000000">48,51</stats></energy>
+<humidity><stats count="2" grid="900">55,56</stats></humidity>
+</devicestats>`
+
+func TestParseDeviceStats(t *testing.T) {
+	now := time.Unix(1700000100, 0)
+	stats, err := parseDeviceStats([]byte(deviceStatsXML), now)
+	assert.NilError(t, err)
+
+	end := time.Unix(1700000000, 0)
+	assert.Equal(t, len(stats.Temperature), 1)
+	assert.Equal(t, stats.Temperature[0].Grid, 15*time.Minute)
+	assert.DeepEqual(t, stats.Temperature[0].Samples, []AvmStatsSample{
+		{Time: end.Add(-30 * time.Minute), Value: 21.8},
+		{Time: end, Value: 22.5},
+	})
+	assert.Equal(t, stats.Voltage[0].Samples[1].Value, 229.174)
+	assert.Equal(t, stats.Power[0].Samples[1].Value, 12.5)
+	assert.Equal(t, len(stats.Energy), 2)
+	assert.Equal(t, stats.Energy[1].Grid, 24*time.Hour)
+	assert.DeepEqual(t, stats.Energy[1].Samples[0], AvmStatsSample{Time: end.Add(-24 * time.Hour), Value: 51})
+
+	// humidity has no datatime, the newest sample is the time of the query
+	assert.Equal(t, stats.Humidity[0].Samples[1].Time, now)
+	assert.Equal(t, stats.Humidity[0].Samples[0].Time, now.Add(-15*time.Minute))
+
+	_, err = parseDeviceStats([]byte(`<devicestats><power><stats grid="10">1,x</stats></power></devicestats>`), now)
+	var parseErr *ParseError
+	assert.Assert(t, errors.As(err, &parseErr))
+}
+
+func TestGetDeviceStats(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("getbasicdevicestats", deviceStatsXML)
+
+	stats, err := f.GetDeviceStats("11630 ANON01")
+	assert.NilError(t, err)
+	assert.Equal(t, len(stats.Power[0].Samples), 2)
+	assert.Equal(t, len(stats.Energy), 2)
+}
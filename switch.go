@@ -0,0 +1,152 @@
+package freepslib
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// parseSwitchState parses the plain-text answer of the setswitch* and getswitchstate commands
+func parseSwitchState(ain string, byt []byte) (bool, error) {
+	switch string(byt) {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	case "inval":
+		return false, &DeviceError{AIN: ain, Err: ErrInvalidValue}
+	}
+	return false, &ParseError{Format: "plain text", Body: byt, Err: strconv.ErrSyntax}
+}
+
+// parseIntValue parses plain-text integer answers like the ones of getswitchpower and gethkrtsoll
+func parseIntValue(ain string, byt []byte) (int, error) {
+	if string(byt) == "inval" {
+		return 0, &DeviceError{AIN: ain, Err: ErrInvalidValue}
+	}
+	v, err := strconv.Atoi(string(byt))
+	if err != nil {
+		return 0, &ParseError{Format: "plain text", Body: byt, Err: err}
+	}
+	return v, nil
+}
+
+// checkPresent replaces an "inval" answer by ErrDeviceNotPresent if the device is not connected
+func (f *Freeps) checkPresent(ctx context.Context, ain string, err error) error {
+	if !errors.Is(err, ErrInvalidValue) {
+		return err
+	}
+	present, presentErr := f.GetSwitchPresentContext(ctx, ain)
+	if presentErr == nil && !present {
+		return &DeviceError{AIN: ain, Err: ErrDeviceNotPresent}
+	}
+	return err
+}
+
+func (f *Freeps) querySwitchState(ctx context.Context, switchcmd string, ain string) (bool, error) {
+	byt, err := f.queryHomeAutomation(ctx, switchcmd, ain, map[string]string{})
+	if err != nil {
+		return false, err
+	}
+	state, err := parseSwitchState(ain, byt)
+	return state, f.checkPresent(ctx, ain, err)
+}
+
+// SwitchOn turns on the outlet and returns its new state
+func (f *Freeps) SwitchOn(ain string) (bool, error) {
+	return f.SwitchOnContext(context.Background(), ain)
+}
+
+func (f *Freeps) SwitchOnContext(ctx context.Context, ain string) (bool, error) {
+	return f.querySwitchState(ctx, "setswitchon", ain)
+}
+
+// SwitchOff turns off the outlet and returns its new state
+func (f *Freeps) SwitchOff(ain string) (bool, error) {
+	return f.SwitchOffContext(context.Background(), ain)
+}
+
+func (f *Freeps) SwitchOffContext(ctx context.Context, ain string) (bool, error) {
+	return f.querySwitchState(ctx, "setswitchoff", ain)
+}
+
+// SwitchToggle toggles the outlet and returns its new state
+func (f *Freeps) SwitchToggle(ain string) (bool, error) {
+	return f.SwitchToggleContext(context.Background(), ain)
+}
+
+func (f *Freeps) SwitchToggleContext(ctx context.Context, ain string) (bool, error) {
+	return f.querySwitchState(ctx, "setswitchtoggle", ain)
+}
+
+// GetSwitchState returns true if the outlet is on, ErrInvalidValue if the state is unknown
+// and ErrDeviceNotPresent if the outlet is not connected
+func (f *Freeps) GetSwitchState(ain string) (bool, error) {
+	return f.GetSwitchStateContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetSwitchStateContext(ctx context.Context, ain string) (bool, error) {
+	return f.querySwitchState(ctx, "getswitchstate", ain)
+}
+
+// GetSwitchPresent returns true if the device is connected to the FRITZ!Box
+func (f *Freeps) GetSwitchPresent(ain string) (bool, error) {
+	return f.GetSwitchPresentContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetSwitchPresentContext(ctx context.Context, ain string) (bool, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getswitchpresent", ain, map[string]string{})
+	if err != nil {
+		return false, err
+	}
+	return parseSwitchState(ain, byt)
+}
+
+// GetSwitchPower returns the current power consumption in mW
+func (f *Freeps) GetSwitchPower(ain string) (int, error) {
+	return f.GetSwitchPowerContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetSwitchPowerContext(ctx context.Context, ain string) (int, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getswitchpower", ain, map[string]string{})
+	if err != nil {
+		return 0, err
+	}
+	v, err := parseIntValue(ain, byt)
+	return v, f.checkPresent(ctx, ain, err)
+}
+
+// GetSwitchEnergy returns the energy consumed since the outlet was set up in Wh
+func (f *Freeps) GetSwitchEnergy(ain string) (int, error) {
+	return f.GetSwitchEnergyContext(context.Background(), ain)
+}
+
+func (f *Freeps) GetSwitchEnergyContext(ctx context.Context, ain string) (int, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getswitchenergy", ain, map[string]string{})
+	if err != nil {
+		return 0, err
+	}
+	v, err := parseIntValue(ain, byt)
+	return v, f.checkPresent(ctx, ain, err)
+}
+
+// GetSwitchList returns the AINs of all outlets
+func (f *Freeps) GetSwitchList() ([]string, error) {
+	return f.GetSwitchListContext(context.Background())
+}
+
+func (f *Freeps) GetSwitchListContext(ctx context.Context) ([]string, error) {
+	byt, err := f.queryHomeAutomation(ctx, "getswitchlist", "", map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	ains := []string{}
+	for _, ain := range strings.Split(string(byt), ",") {
+		ain = strings.TrimSpace(ain)
+		if ain != "" {
+			ains = append(ains, ain)
+		}
+	}
+	return ains, nil
+}
@@ -0,0 +1,128 @@
+package freepslib
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseSwitchState(t *testing.T) {
+	state, err := parseSwitchState("ain", []byte("1"))
+	assert.NilError(t, err)
+	assert.Assert(t, state)
+	state, err = parseSwitchState("ain", []byte("0"))
+	assert.NilError(t, err)
+	assert.Assert(t, !state)
+
+	_, err = parseSwitchState("ain", []byte("inval"))
+	assert.Assert(t, errors.Is(err, ErrInvalidValue))
+	var devErr *DeviceError
+	assert.Assert(t, errors.As(err, &devErr))
+	assert.Equal(t, devErr.AIN, "ain")
+
+	_, err = parseSwitchState("ain", []byte("<html>"))
+	var parseErr *ParseError
+	assert.Assert(t, errors.As(err, &parseErr))
+}
+
+func TestSwitchAPI(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	state := "0"
+	box.handleSwitch("setswitchon", func(w http.ResponseWriter, r *http.Request) {
+		state = "1"
+		w.Write([]byte(state + "\n"))
+	})
+	box.handleSwitch("setswitchoff", func(w http.ResponseWriter, r *http.Request) {
+		state = "0"
+		w.Write([]byte(state + "\n"))
+	})
+	box.handleSwitch("setswitchtoggle", func(w http.ResponseWriter, r *http.Request) {
+		state = map[string]string{"0": "1", "1": "0"}[state]
+		w.Write([]byte(state + "\n"))
+	})
+	box.handleSwitch("getswitchstate", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ain") == "unknown" {
+			w.Write([]byte("inval\n"))
+			return
+		}
+		w.Write([]byte(state + "\n"))
+	})
+	box.respond("getswitchpower", "12340")
+	box.respond("getswitchenergy", "707")
+	box.respond("getswitchlist", "02361 0000734,087610 0000001")
+
+	on, err := f.SwitchOn("02361 0000734")
+	assert.NilError(t, err)
+	assert.Assert(t, on)
+	on, err = f.GetSwitchState("02361 0000734")
+	assert.NilError(t, err)
+	assert.Assert(t, on)
+	on, err = f.SwitchToggle("02361 0000734")
+	assert.NilError(t, err)
+	assert.Assert(t, !on)
+	on, err = f.SwitchToggle("02361 0000734")
+	assert.NilError(t, err)
+	assert.Assert(t, on)
+	on, err = f.SwitchOff("02361 0000734")
+	assert.NilError(t, err)
+	assert.Assert(t, !on)
+
+	_, err = f.GetSwitchState("unknown")
+	assert.Assert(t, errors.Is(err, ErrInvalidValue))
+
+	power, err := f.GetSwitchPower("02361 0000734")
+	assert.NilError(t, err)
+	assert.Equal(t, power, 12340)
+	energy, err := f.GetSwitchEnergy("02361 0000734")
+	assert.NilError(t, err)
+	assert.Equal(t, energy, 707)
+
+	ains, err := f.GetSwitchList()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ains, []string{"02361 0000734", "087610 0000001"})
+
+	box.respond("getswitchlist", "")
+	ains, err = f.GetSwitchList()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ains, []string{})
+}
+
+// presentOnly registers a getswitchpresent handler reporting only the given AIN as connected
+func presentOnly(box *fakeBox, ain string) {
+	box.handleSwitch("getswitchpresent", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("ain") == ain {
+			w.Write([]byte("1\n"))
+		} else {
+			w.Write([]byte("0\n"))
+		}
+	})
+}
+
+func TestSwitchNotPresent(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("getswitchstate", "inval")
+	box.respond("getswitchpower", "inval")
+	presentOnly(box, "11630 ANON01")
+
+	present, err := f.GetSwitchPresent("11630 ANON01")
+	assert.NilError(t, err)
+	assert.Assert(t, present)
+
+	_, err = f.GetSwitchState("11630 ANON02")
+	assert.Assert(t, errors.Is(err, ErrDeviceNotPresent), err)
+	_, err = f.GetSwitchPower("11630 ANON02")
+	assert.Assert(t, errors.Is(err, ErrDeviceNotPresent), err)
+	var devErr *DeviceError
+	assert.Assert(t, errors.As(err, &devErr))
+	assert.Equal(t, devErr.AIN, "11630 ANON02")
+
+	// a connected device with an unknown state
+	_, err = f.GetSwitchState("11630 ANON01")
+	assert.Assert(t, errors.Is(err, ErrInvalidValue), err)
+	assert.Assert(t, !errors.Is(err, ErrDeviceNotPresent))
+}
@@ -0,0 +1,141 @@
+package freepslib
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// AvmTemplateApplyMask is the set of settings a template changes, parsed from the children of applymask
+type AvmTemplateApplyMask uint32
+
+const (
+	ApplyHkrSummer AvmTemplateApplyMask = 1 << iota
+	ApplyHkrTemperature
+	ApplyHkrHolidays
+	ApplyHkrTimeTable
+	ApplyRelayManual
+	ApplyRelayAutomatic
+	ApplyLevel
+	ApplyColor
+	ApplyDialHu
+	ApplyMainWifi
+	ApplyGuestWifi
+	ApplyTamControl
+	ApplyHttpRequest
+	ApplyTimerControl
+	ApplySwitchMaster
+	ApplyCustomNotification
+)
+
+var applyMaskNames = []struct {
+	flag AvmTemplateApplyMask
+	name string
+}{
+	{ApplyHkrSummer, "hkr_summer"},
+	{ApplyHkrTemperature, "hkr_temperature"},
+	{ApplyHkrHolidays, "hkr_holidays"},
+	{ApplyHkrTimeTable, "hkr_time_table"},
+	{ApplyRelayManual, "relay_manual"},
+	{ApplyRelayAutomatic, "relay_automatic"},
+	{ApplyLevel, "level"},
+	{ApplyColor, "color"},
+	{ApplyDialHu, "dialhu"},
+	{ApplyMainWifi, "main_wifi"},
+	{ApplyGuestWifi, "guest_wifi"},
+	{ApplyTamControl, "tam_control"},
+	{ApplyHttpRequest, "http_request"},
+	{ApplyTimerControl, "timer_control"},
+	{ApplySwitchMaster, "switch_master"},
+	{ApplyCustomNotification, "custom_notification"},
+}
+
+// Has returns true if all bits of flag are set
+func (m AvmTemplateApplyMask) Has(flag AvmTemplateApplyMask) bool {
+	return m&flag == flag
+}
+
+// Names returns the element names of all set flags, in the order of the AHA documentation
+func (m AvmTemplateApplyMask) Names() []string {
+	names := []string{}
+	for _, n := range applyMaskNames {
+		if m.Has(n.flag) {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+func (m AvmTemplateApplyMask) String() string {
+	return strings.Join(m.Names(), ",")
+}
+
+// UnmarshalXML sets a flag for each known child element, unknown ones are ignored
+func (m *AvmTemplateApplyMask) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*m = 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch el := tok.(type) {
+		case xml.StartElement:
+			for _, n := range applyMaskNames {
+				if n.name == el.Name.Local {
+					*m |= n.flag
+				}
+			}
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// AvmTemplateRef references a sub-template or trigger by its identifier
+type AvmTemplateRef struct {
+	Identifier string `xml:"identifier,attr"`
+}
+
+func parseTemplateList(byt []byte) (*AvmTemplateList, error) {
+	var avm_resp *AvmTemplateList
+	err := xml.Unmarshal(byt, &avm_resp)
+	if err != nil {
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+	return avm_resp, nil
+}
+
+// GetTemplate returns the template with the given identifier
+func (tl *AvmTemplateList) GetTemplate(identifier string) *AvmTemplate {
+	for i := range tl.Template {
+		if tl.Template[i].Identifier == identifier {
+			return &tl.Template[i]
+		}
+	}
+	return nil
+}
+
+// ApplyTemplate applies the template and returns it, its ApplyMask, Devices and SubTemplates describe what was changed
+func (f *Freeps) ApplyTemplate(identifier string) (*AvmTemplate, error) {
+	return f.ApplyTemplateContext(context.Background(), identifier)
+}
+
+func (f *Freeps) ApplyTemplateContext(ctx context.Context, identifier string) (*AvmTemplate, error) {
+	tl, err := f.GetTemplateListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	template := tl.GetTemplate(identifier)
+	if template == nil {
+		return nil, fmt.Errorf("%w: %v", ErrTemplateNotFound, identifier)
+	}
+	_, err = f.queryHomeAutomation(ctx, "applytemplate", identifier, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
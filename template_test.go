@@ -0,0 +1,73 @@
+package freepslib
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const templateListXML = `<templatelist version="1">
+<template identifier="tmp6F0093-391363146" id="30103" functionbitmask="6784" applymask="522">
+<name>Urlaub</name>
+<devices><device identifier="11795 ANON01" /><device identifier="11630 ANON01" /></devices>
+<applymask><hkr_summer /><hkr_temperature /><relay_manual /><custom_notification /><unknown_future_setting /></applymask>
+<sub_templates><template identifier="tmp6F0093-391363147" /></sub_templates>
+<triggers><trigger identifier="trg6F0093-391363148" /></triggers>
+</template>
+<template identifier="tmp6F0093-391363147" id="30104" functionbitmask="320">
+<name>Licht aus</name>
+<devices />
+<applymask><level /><color /></applymask>
+<sub_templates />
+<triggers />
+</template>
+</templatelist>`
+
+func TestTemplateListUnmarshal(t *testing.T) {
+	tl, err := parseTemplateList([]byte(templateListXML))
+	assert.NilError(t, err)
+	assert.Equal(t, len(tl.Template), 2)
+
+	vacation := tl.GetTemplate("tmp6F0093-391363146")
+	assert.Assert(t, vacation != nil)
+	assert.Equal(t, vacation.Name, "Urlaub")
+	assert.Equal(t, vacation.FunctionBitmask, AvmFunctionBitmask(6784))
+	assert.Equal(t, len(vacation.Devices.Device), 2)
+	assert.Equal(t, vacation.ApplyMask, ApplyHkrSummer|ApplyHkrTemperature|ApplyRelayManual|ApplyCustomNotification)
+	assert.Equal(t, vacation.ApplyMask.String(), "hkr_summer,hkr_temperature,relay_manual,custom_notification")
+	assert.DeepEqual(t, vacation.SubTemplates, []AvmTemplateRef{{Identifier: "tmp6F0093-391363147"}})
+	assert.DeepEqual(t, vacation.Triggers, []AvmTemplateRef{{Identifier: "trg6F0093-391363148"}})
+
+	lights := tl.GetTemplate("tmp6F0093-391363147")
+	assert.Assert(t, lights.ApplyMask.Has(ApplyLevel|ApplyColor))
+	assert.Assert(t, !lights.ApplyMask.Has(ApplyHkrSummer))
+	assert.Equal(t, len(lights.SubTemplates), 0)
+
+	assert.Assert(t, tl.GetTemplate("unknown") == nil)
+}
+
+func TestApplyTemplate(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("gettemplatelistinfos", templateListXML)
+
+	applied := []string{}
+	box.handleSwitch("applytemplate", func(w http.ResponseWriter, r *http.Request) {
+		applied = append(applied, r.URL.Query().Get("ain"))
+		w.Write([]byte("30103\n"))
+	})
+
+	template, err := f.ApplyTemplate("tmp6F0093-391363146")
+	assert.NilError(t, err)
+	assert.Equal(t, template.ID, "30103")
+	assert.Assert(t, template.ApplyMask.Has(ApplyHkrSummer))
+	assert.DeepEqual(t, applied, []string{"tmp6F0093-391363146"})
+
+	_, err = f.ApplyTemplate("unknown")
+	assert.Assert(t, errors.Is(err, ErrTemplateNotFound), err)
+	assert.Assert(t, !errors.Is(err, ErrDeviceNotFound))
+	assert.Error(t, err, "template not found: unknown")
+	assert.Equal(t, len(applied), 1)
+}
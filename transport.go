@@ -0,0 +1,167 @@
+package freepslib
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// baseURL returns scheme, address and port of the FRITZ!Box web interface without trailing slash
+func (f *Freeps) baseURL() string {
+	scheme := f.conf.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	host := f.conf.Address
+	if f.conf.Port != 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(f.conf.Port))
+	}
+	return scheme + "://" + host
+}
+
+// DefaultTimeout limits requests whose context has no deadline if FBconfig.Timeout is not set
+const DefaultTimeout = 10 * time.Second
+
+// timeoutTransport applies a timeout to requests without a deadline, a deadline of the context always wins
+type timeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+// cancelOnClose keeps the timeout context alive until the response body was read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, ok := req.Context().Deadline(); ok {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// newHttpClient creates the client that is used for all requests of a Freeps instance
+func newHttpClient(conf *FBconfig) (*http.Client, error) {
+	if conf.HTTPClient != nil {
+		return conf.HTTPClient, nil
+	}
+	if conf.Scheme != "" && conf.Scheme != "http" && conf.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme: %v", conf.Scheme)
+	}
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if conf.Transport != nil {
+		return &http.Client{Transport: &timeoutTransport{base: conf.Transport, timeout: timeout}}, nil
+	}
+
+	tlsConfig, err := newTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: &timeoutTransport{base: tr, timeout: timeout}}, nil
+}
+
+func newTLSConfig(conf *FBconfig) (*tls.Config, error) {
+	if conf.CertFingerprint != "" || conf.CertFingerprintFile != "" {
+		pinner, err := newCertPinner(conf.CertFingerprint, conf.CertFingerprintFile)
+		if err != nil {
+			return nil, err
+		}
+		// the certificate chain is not verified, the fingerprint is all that matters
+		return &tls.Config{InsecureSkipVerify: true, VerifyConnection: pinner.verify}, nil
+	}
+
+	if conf.CACertFile != "" {
+		pem, err := os.ReadFile(conf.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %v", conf.CACertFile)
+		}
+		return &tls.Config{RootCAs: pool}, nil
+	}
+
+	if conf.VerifyTLS {
+		return &tls.Config{}, nil
+	}
+	// the FRITZ!Box uses a self signed certificate by default
+	return &tls.Config{InsecureSkipVerify: true}, nil
+}
+
+// certPinner accepts only a certificate with a known SHA-256 fingerprint. If the
+// fingerprint is unknown, the first certificate seen is trusted and stored to file.
+type certPinner struct {
+	mu          sync.Mutex
+	fingerprint string
+	file        string
+}
+
+func normalizeFingerprint(fingerprint string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+}
+
+func newCertPinner(fingerprint string, file string) (*certPinner, error) {
+	p := &certPinner{fingerprint: normalizeFingerprint(fingerprint), file: file}
+	if p.fingerprint == "" && file != "" {
+		byt, err := os.ReadFile(file)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("cannot read certificate fingerprint: %w", err)
+		}
+		p.fingerprint = normalizeFingerprint(string(byt))
+	}
+	return p, nil
+}
+
+func (p *certPinner) verify(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("FRITZ!Box did not present a certificate")
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fingerprint == "" {
+		// trust on first use
+		if err := os.WriteFile(p.file, []byte(fingerprint+"\n"), 0600); err != nil {
+			return fmt.Errorf("cannot store certificate fingerprint: %w", err)
+		}
+		p.fingerprint = fingerprint
+		return nil
+	}
+	if p.fingerprint != fingerprint {
+		return fmt.Errorf("certificate fingerprint %v does not match pinned fingerprint %v", fingerprint, p.fingerprint)
+	}
+	return nil
+}
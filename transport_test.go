@@ -0,0 +1,117 @@
+package freepslib
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBaseURL(t *testing.T) {
+	f, err := NewFreepsLib(&FBconfig{Address: "fritz.box"})
+	assert.NilError(t, err)
+	assert.Equal(t, f.baseURL(), "https://fritz.box")
+
+	f, err = NewFreepsLib(&FBconfig{Address: "example.myfritz.net", Port: 45678})
+	assert.NilError(t, err)
+	assert.Equal(t, f.baseURL(), "https://example.myfritz.net:45678")
+
+	f, err = NewFreepsLib(&FBconfig{Address: "192.168.178.1", Scheme: "http"})
+	assert.NilError(t, err)
+	assert.Equal(t, f.baseURL(), "http://192.168.178.1")
+
+	_, err = NewFreepsLib(&FBconfig{Address: "fritz.box", Scheme: "ftp"})
+	assert.ErrorContains(t, err, "unsupported scheme")
+}
+
+func TestClientIsReused(t *testing.T) {
+	f, err := NewFreepsLib(&FBconfig{Address: "fritz.box"})
+	assert.NilError(t, err)
+	assert.Assert(t, f.getHttpClient() == f.getHttpClient())
+}
+
+func TestCertificatePinning(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	sum := sha256.Sum256(box.Certificate().Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	pinFile := filepath.Join(t.TempDir(), "fritzbox.fingerprint")
+
+	// trust on first use
+	f, err := NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), Password: "secret", CertFingerprintFile: pinFile})
+	assert.NilError(t, err)
+	assert.NilError(t, f.Login())
+	stored, err := os.ReadFile(pinFile)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(stored)), fingerprint)
+
+	// the stored fingerprint is used by the next instance
+	f, err = NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), Password: "secret", CertFingerprintFile: pinFile})
+	assert.NilError(t, err)
+	assert.NilError(t, f.Login())
+
+	f, err = NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), Password: "secret", CertFingerprint: strings.ToUpper(fingerprint)})
+	assert.NilError(t, err)
+	assert.NilError(t, f.Login())
+
+	f, err = NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), Password: "secret", CertFingerprint: strings.Repeat("00", 32)})
+	assert.NilError(t, err)
+	assert.ErrorContains(t, f.Login(), "does not match pinned fingerprint")
+}
+
+func TestCACertFile(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: box.Certificate().Raw}), 0600)
+	assert.NilError(t, err)
+
+	f, err := NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), Password: "secret", CACertFile: caFile})
+	assert.NilError(t, err)
+	assert.NilError(t, f.Login())
+
+	f, err = NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), Password: "secret", VerifyTLS: true})
+	assert.NilError(t, err)
+	assert.ErrorContains(t, f.Login(), "certificate")
+
+	_, err = NewFreepsLib(&FBconfig{Address: "fritz.box", CACertFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "cannot read CA bundle")
+}
+
+func TestCustomHTTPClient(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	client := box.Client()
+	f, err := NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), Password: "secret", HTTPClient: client})
+	assert.NilError(t, err)
+	assert.Assert(t, f.getHttpClient() == client)
+	assert.NilError(t, f.Login())
+}
+
+func TestTimeout(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	box.handleSwitch("getswitchlist", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte("11630 ANON01\n"))
+	})
+	f, err := NewFreepsLib(&FBconfig{Address: box.Listener.Addr().String(), User: "freeps", Password: "secret", Timeout: 50 * time.Millisecond})
+	assert.NilError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NilError(t, f.LoginContext(ctx))
+
+	// the configured timeout applies without a deadline
+	_, err = f.HomeAutomation("getswitchlist", "", map[string]string{})
+	assert.Assert(t, errors.Is(err, context.DeadlineExceeded), err)
+
+	// a longer deadline of the context is not cut off
+	byt, err := f.HomeAutomationContext(ctx, "getswitchlist", "", map[string]string{})
+	assert.NilError(t, err)
+	assert.Equal(t, string(byt), "11630 ANON01")
+}
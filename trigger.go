@@ -0,0 +1,52 @@
+package freepslib
+
+import (
+	"context"
+	"encoding/xml"
+)
+
+type AvmTrigger struct {
+	Name       string `xml:"name"`
+	Identifier string `xml:"identifier,attr"`
+	Active     bool   `xml:"active,attr"`
+}
+
+type AvmTriggerList struct {
+	Trigger []AvmTrigger `xml:"trigger"`
+}
+
+func parseTriggerList(byt []byte) (*AvmTriggerList, error) {
+	var avm_resp *AvmTriggerList
+	err := xml.Unmarshal(byt, &avm_resp)
+	if err != nil {
+		return nil, &ParseError{Format: "XML", Body: byt, Err: err}
+	}
+	return avm_resp, nil
+}
+
+// GetTriggerList returns the automation triggers configured on the FRITZ!Box
+func (f *Freeps) GetTriggerList() (*AvmTriggerList, error) {
+	return f.GetTriggerListContext(context.Background())
+}
+
+func (f *Freeps) GetTriggerListContext(ctx context.Context) (*AvmTriggerList, error) {
+	byt, err := f.queryHomeAutomation(ctx, "gettriggerlistinfos", "", map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	return parseTriggerList(byt)
+}
+
+// SetTriggerActive enables or disables the trigger with the given identifier
+func (f *Freeps) SetTriggerActive(ain string, active bool) error {
+	return f.SetTriggerActiveContext(context.Background(), ain, active)
+}
+
+func (f *Freeps) SetTriggerActiveContext(ctx context.Context, ain string, active bool) error {
+	payload := map[string]string{"active": "0"}
+	if active {
+		payload["active"] = "1"
+	}
+	_, err := f.queryHomeAutomation(ctx, "settriggeractive", ain, payload)
+	return err
+}
@@ -0,0 +1,39 @@
+package freepslib
+
+import (
+	"net/http"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const triggerListXML = `<triggerlist version="1">
+<trigger identifier="trg9A1B2C-3D4E5F6A7" active="1"><name>Heizung morgens</name></trigger>
+<trigger identifier="trg9A1B2C-3D4E5F6A8" active="0"><name>Licht Urlaub</name></trigger>
+</triggerlist>`
+
+func TestGetTriggerList(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	box.respond("gettriggerlistinfos", triggerListXML)
+
+	tl, err := f.GetTriggerList()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tl.Trigger, []AvmTrigger{
+		{Name: "Heizung morgens", Identifier: "trg9A1B2C-3D4E5F6A7", Active: true},
+		{Name: "Licht Urlaub", Identifier: "trg9A1B2C-3D4E5F6A8", Active: false},
+	})
+}
+
+func TestSetTriggerActive(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+
+	var requests []string
+	box.handleSwitch("settriggeractive", func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query().Get("ain")+"="+r.URL.Query().Get("active"))
+	})
+	assert.NilError(t, f.SetTriggerActive("trg9A1B2C-3D4E5F6A7", false))
+	assert.NilError(t, f.SetTriggerActive("trg9A1B2C-3D4E5F6A8", true))
+	assert.DeepEqual(t, requests, []string{"trg9A1B2C-3D4E5F6A7=0", "trg9A1B2C-3D4E5F6A8=1"})
+}
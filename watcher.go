@@ -0,0 +1,261 @@
+package freepslib
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is used by the Watcher if no interval is configured
+const DefaultWatchInterval = 10 * time.Second
+
+// Clock abstracts time for the Watcher, tests can provide a fake one
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type WatcherConfig struct {
+	// Interval between two polls of the device list, DefaultWatchInterval if 0
+	Interval time.Duration
+	// PowerThreshold in mW, a PowerThresholdCrossed event is emitted when the power of a device rises above or falls to it.
+	// No such events are emitted if it is 0.
+	PowerThreshold int
+	// Clock defaults to the system clock
+	Clock Clock
+	// ButtonStateFile stores the last press of every button, so presses during a restart are reported and none twice
+	ButtonStateFile string
+}
+
+// Event is implemented by all events emitted by the Watcher, use a type switch to access the details
+type Event interface {
+	EventAIN() string
+	EventTime() time.Time
+}
+
+// DeviceEventBase contains the fields shared by all events
+type DeviceEventBase struct {
+	AIN  string
+	Time time.Time
+}
+
+func (e DeviceEventBase) EventAIN() string     { return e.AIN }
+func (e DeviceEventBase) EventTime() time.Time { return e.Time }
+
+type DeviceAppeared struct {
+	DeviceEventBase
+	Device *AvmDevice
+}
+
+type DeviceDisappeared struct {
+	DeviceEventBase
+	Device *AvmDevice // the last known state
+}
+
+type PresentChanged struct {
+	DeviceEventBase
+	Present bool
+}
+
+// SwitchChanged is emitted if the switch or simpleonoff state of a device changed
+type SwitchChanged struct {
+	DeviceEventBase
+	On bool
+}
+
+type PowerThresholdCrossed struct {
+	DeviceEventBase
+	Power int // in mW
+	Above bool
+}
+
+type TemperatureChanged struct {
+	DeviceEventBase
+	Old, New float64 // in °C
+}
+
+type HkrTargetChanged struct {
+	DeviceEventBase
+	Old, New HkrTemperature
+}
+
+type AlertChanged struct {
+	DeviceEventBase
+	Old, New AvmAlertStatus
+}
+
+// BatteryLowChanged is emitted if a device reports a low battery and again when it was replaced
+type BatteryLowChanged struct {
+	DeviceEventBase
+	Low bool
+}
+
+// Watcher polls the device list and emits events for the differences between two polls
+type Watcher struct {
+	f    *Freeps
+	conf WatcherConfig
+
+	mu      sync.Mutex
+	last    map[string]*AvmDevice
+	buttons buttonTracker
+}
+
+func (f *Freeps) NewWatcher(conf WatcherConfig) *Watcher {
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultWatchInterval
+	}
+	if conf.Clock == nil {
+		conf.Clock = realClock{}
+	}
+	return &Watcher{f: f, conf: conf, buttons: buttonTracker{file: conf.ButtonStateFile}}
+}
+
+// Poll fetches the device list once and returns the events since the last poll.
+// The first poll only records the current state and returns no events, except for
+// button presses newer than the ones stored in the ButtonStateFile.
+func (w *Watcher) Poll(ctx context.Context) ([]Event, error) {
+	dl, err := w.f.GetDeviceListContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := w.conf.Clock.Now()
+
+	current := make(map[string]*AvmDevice, len(dl.Device))
+	for i := range dl.Device {
+		current[dl.Device[i].AIN] = &dl.Device[i]
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.buttons.loaded {
+		if err := w.buttons.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	events := []Event{}
+	buttonsChanged := false
+	for i := range dl.Device {
+		presses, changed := w.buttons.presses(&dl.Device[i], w.last != nil)
+		events = append(events, presses...)
+		buttonsChanged = buttonsChanged || changed
+	}
+	if buttonsChanged {
+		if err := w.buttons.save(); err != nil {
+			w.f.logger.Warnf("Watcher failed to store button state: %v", err)
+		}
+	}
+
+	if w.last != nil {
+		for _, dev := range dl.Device {
+			events = append(events, w.diffDevice(now, w.last[dev.AIN], current[dev.AIN])...)
+		}
+		for ain, old := range w.last {
+			if current[ain] == nil {
+				events = append(events, DeviceDisappeared{DeviceEventBase{ain, now}, old})
+			}
+		}
+	}
+	w.last = current
+	return events, nil
+}
+
+// Run polls until ctx is done and passes all events to handler, errors are logged and the next poll is tried
+func (w *Watcher) Run(ctx context.Context, handler func(Event)) error {
+	for {
+		events, err := w.Poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			w.f.logger.Warnf("Watcher failed to get device list: %v", err)
+		}
+		for _, e := range events {
+			handler(e)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.conf.Clock.After(w.conf.Interval):
+		}
+	}
+}
+
+// Watch runs the watcher in the background and delivers the events over the returned channel,
+// which is closed after ctx is done
+func (w *Watcher) Watch(ctx context.Context) <-chan Event {
+	ch := make(chan Event)
+	go func() {
+		defer close(ch)
+		w.Run(ctx, func(e Event) {
+			select {
+			case ch <- e:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return ch
+}
+
+func switchState(dev *AvmDevice) (on bool, ok bool) {
+	if dev.Switch != nil {
+		return dev.Switch.State, true
+	}
+	if dev.SimpleOnOff != nil {
+		return dev.SimpleOnOff.State, true
+	}
+	return false, false
+}
+
+func batteryLow(dev *AvmDevice) bool {
+	if dev.BatteryLow != nil {
+		return *dev.BatteryLow
+	}
+	return dev.HKR != nil && dev.HKR.Batterylow
+}
+
+// diffDevice compares two states of a device, old is nil if the device is new
+func (w *Watcher) diffDevice(now time.Time, old *AvmDevice, dev *AvmDevice) []Event {
+	base := DeviceEventBase{AIN: dev.AIN, Time: now}
+	if old == nil {
+		return []Event{DeviceAppeared{base, dev}}
+	}
+
+	events := []Event{}
+	if old.Present != dev.Present {
+		events = append(events, PresentChanged{base, dev.Present})
+	}
+	oldOn, oldOk := switchState(old)
+	on, ok := switchState(dev)
+	if oldOk && ok && oldOn != on {
+		events = append(events, SwitchChanged{base, on})
+	}
+	if w.conf.PowerThreshold != 0 && old.Powermeter != nil && dev.Powermeter != nil {
+		wasAbove := old.Powermeter.Power > w.conf.PowerThreshold
+		above := dev.Powermeter.Power > w.conf.PowerThreshold
+		if wasAbove != above {
+			events = append(events, PowerThresholdCrossed{base, dev.Powermeter.Power, above})
+		}
+	}
+	if old.Temperature != nil && dev.Temperature != nil && old.Temperature.Celsius != dev.Temperature.Celsius {
+		events = append(events, TemperatureChanged{base, old.Temperature.Degrees(), dev.Temperature.Degrees()})
+	}
+	if old.HKR != nil && dev.HKR != nil && old.HKR.Tsoll != dev.HKR.Tsoll {
+		events = append(events, HkrTargetChanged{base, old.HKR.TargetTemperature(), dev.HKR.TargetTemperature()})
+	}
+	oldAlert, oldOk := old.AlertStatus()
+	alert, ok := dev.AlertStatus()
+	if oldOk && ok && (oldAlert.State != alert.State || !oldAlert.LastChange.Equal(alert.LastChange)) {
+		events = append(events, AlertChanged{base, oldAlert, alert})
+	}
+	if batteryLow(old) != batteryLow(dev) {
+		events = append(events, BatteryLowChanged{base, batteryLow(dev)})
+	}
+	return events
+}
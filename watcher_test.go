@@ -0,0 +1,206 @@
+package freepslib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// fakeClock only moves when Advance is called, waiting receives the duration of every call to After
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []fakeTimer
+	waiting chan time.Duration
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1741500000, 0), waiting: make(chan time.Duration, 10)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{c.now.Add(d), ch})
+	c.waiting <- d
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := []fakeTimer{}
+	for _, t := range c.timers {
+		if t.deadline.After(c.now) {
+			pending = append(pending, t)
+		} else {
+			t.ch <- c.now
+		}
+	}
+	c.timers = pending
+}
+
+// fakeDeviceList serves a device list that the test can replace between polls
+type fakeDeviceList struct {
+	mu  sync.Mutex
+	xml string
+}
+
+func (l *fakeDeviceList) set(devices ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.xml = `<devicelist version="1">`
+	for _, d := range devices {
+		l.xml += d
+	}
+	l.xml += `</devicelist>`
+}
+
+func (l *fakeDeviceList) register(box *fakeBox) {
+	box.handleSwitch("getdevicelistinfos", func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		fmt.Fprint(w, l.xml+"\n")
+	})
+}
+
+func outletXML(present int, on int, power int) string {
+	return fmt.Sprintf(`<device identifier="11630 ANON01" id="25"><present>%v</present><name>Outlet</name>`+
+		`<switch><state>%v</state></switch><powermeter><power>%v</power></powermeter><temperature><celsius>215</celsius></temperature></device>`, present, on, power)
+}
+
+func thermostatXML(tsoll int, batterylow int) string {
+	return fmt.Sprintf(`<device identifier="11795 ANON01" id="16"><present>1</present><name>Thermostat</name><batterylow>%v</batterylow>`+
+		`<hkr><tist>40</tist><tsoll>%v</tsoll></hkr></device>`, batterylow, tsoll)
+}
+
+func contactXML(state int, timestamp int) string {
+	return fmt.Sprintf(`<device identifier="ZDANON02" id="2003"><present>1</present><name>Door</name>`+
+		`<etsiunitinfo><unittype>514</unittype><interfaces>256</interfaces></etsiunitinfo>`+
+		`<alert><state>%v</state><lastalertchgtimestamp>%v</lastalertchgtimestamp></alert></device>`, state, timestamp)
+}
+
+func TestWatcherPoll(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	list := &fakeDeviceList{}
+	list.register(box)
+	clock := newFakeClock()
+	w := f.NewWatcher(WatcherConfig{PowerThreshold: 5000, Clock: clock})
+	ctx := context.Background()
+
+	list.set(outletXML(1, 0, 0), thermostatXML(40, 0))
+	events, err := w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	// nothing changed
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 0)
+
+	list.set(outletXML(1, 1, 12000), thermostatXML(44, 1), contactXML(0, 1741377491))
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	now := clock.Now()
+	assert.DeepEqual(t, events[:4], []Event{
+		SwitchChanged{DeviceEventBase{"11630 ANON01", now}, true},
+		PowerThresholdCrossed{DeviceEventBase{"11630 ANON01", now}, 12000, true},
+		HkrTargetChanged{DeviceEventBase{"11795 ANON01", now}, 40, 44},
+		BatteryLowChanged{DeviceEventBase{"11795 ANON01", now}, true},
+	})
+	appeared, ok := events[4].(DeviceAppeared)
+	assert.Assert(t, ok)
+	assert.Equal(t, appeared.AIN, "ZDANON02")
+	assert.Equal(t, appeared.Device.Name, "Door")
+	assert.Equal(t, len(events), 5)
+
+	// power stays above the threshold
+	list.set(outletXML(1, 1, 8000), thermostatXML(44, 1), contactXML(1, 1741512765))
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 1)
+	alert := events[0].(AlertChanged)
+	assert.Assert(t, !alert.Old.Alarm())
+	assert.Assert(t, alert.New.Alarm())
+	assert.Equal(t, alert.New.Kind, AlertKindContact)
+
+	list.set(outletXML(0, 1, 4000), contactXML(1, 1741512765))
+	events, err = w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.Equal(t, len(events), 3)
+	assert.DeepEqual(t, events[0], PresentChanged{DeviceEventBase{"11630 ANON01", now}, false})
+	assert.DeepEqual(t, events[1], PowerThresholdCrossed{DeviceEventBase{"11630 ANON01", now}, 4000, false})
+	disappeared := events[2].(DeviceDisappeared)
+	assert.Equal(t, disappeared.EventAIN(), "11795 ANON01")
+	assert.Equal(t, disappeared.Device.HKR.Tsoll, 44)
+}
+
+func TestWatcherWithoutPowerThreshold(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	list := &fakeDeviceList{}
+	list.register(box)
+	clock := newFakeClock()
+	w := f.NewWatcher(WatcherConfig{Clock: clock})
+	ctx := context.Background()
+
+	list.set(outletXML(1, 0, 0))
+	_, err := w.Poll(ctx)
+	assert.NilError(t, err)
+
+	list.set(outletXML(1, 1, 12000))
+	events, err := w.Poll(ctx)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, events, []Event{SwitchChanged{DeviceEventBase{"11630 ANON01", clock.Now()}, true}})
+}
+
+func TestWatcherWatch(t *testing.T) {
+	box := newFakeBox(t, "secret")
+	f := box.newFreeps(t, "secret")
+	list := &fakeDeviceList{}
+	list.register(box)
+	list.set(outletXML(1, 0, 0))
+	clock := newFakeClock()
+	w := f.NewWatcher(WatcherConfig{Interval: time.Minute, Clock: clock})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := w.Watch(ctx)
+
+	// the first poll only records the state
+	assert.Equal(t, <-clock.waiting, time.Minute)
+	list.set(outletXML(1, 1, 0))
+	clock.Advance(30 * time.Second)
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event before the interval passed: %v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	clock.Advance(30 * time.Second)
+	e := <-events
+	assert.DeepEqual(t, e, SwitchChanged{DeviceEventBase{"11630 ANON01", clock.Now()}, true})
+	assert.Equal(t, <-clock.waiting, time.Minute)
+
+	cancel()
+	_, open := <-events
+	assert.Assert(t, !open)
+}